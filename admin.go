@@ -0,0 +1,230 @@
+package sql
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AdminHandler returns an http.Handler for operators to see and stop the
+// queries of the connector. It serves:
+//
+//	GET  /        the in-flight queries, the kill pool statistics and the
+//	              recent kills, as HTML to browsers and as JSON otherwise
+//	POST /cancel  kills the in-flight query given by the id form value
+//	GET  /health  checks the kill pool, answering 503 unless it is ready
+//
+// The handler does no authorization of its own: the middleware are
+// applied to it in order, the first one outermost. Mount it under a
+// prefix with http.StripPrefix.
+func (c *Connector) AdminHandler(middleware ...func(http.Handler) http.Handler) http.Handler {
+	var h http.Handler = http.HandlerFunc(c.serveAdmin)
+	for i := len(middleware) - 1; i >= 0; i-- {
+		h = middleware[i](h)
+	}
+	return h
+}
+
+// adminStatus is the JSON document served by the admin handler.
+type adminStatus struct {
+	Connector string        `json:"connector"`
+	Ready     bool          `json:"kill_pool_ready"`
+	Queries   []adminQuery  `json:"queries"`
+	KillPool  adminKillPool `json:"kill_pool"`
+	Kills     []adminKill   `json:"kills"`
+}
+
+type adminQuery struct {
+	ID           QueryID           `json:"id"`
+	ConnectionID ConnectionID      `json:"connection_id"`
+	Query        string            `json:"query"`
+	Args         []interface{}     `json:"args"`
+	Start        time.Time         `json:"start"`
+	Elapsed      string            `json:"elapsed"`
+	Deadline     *time.Time        `json:"deadline,omitempty"`
+	Labels       map[string]string `json:"labels,omitempty"`
+}
+
+type adminKillPool struct {
+	MaxOpenConnections int    `json:"max_open_connections"`
+	OpenConnections    int    `json:"open_connections"`
+	InUse              int    `json:"in_use"`
+	Idle               int    `json:"idle"`
+	WaitCount          int64  `json:"wait_count"`
+	WaitDuration       string `json:"wait_duration"`
+}
+
+type adminKill struct {
+	Time         time.Time    `json:"time"`
+	Event        Event        `json:"event"`
+	ConnectionID ConnectionID `json:"connection_id"`
+	Query        string       `json:"query"`
+	Statement    string       `json:"statement,omitempty"`
+	Latency      string       `json:"latency,omitempty"`
+	Path         KillPath     `json:"path,omitempty"`
+	Cause        string       `json:"cause,omitempty"`
+	Error        string       `json:"error,omitempty"`
+}
+
+func (c *Connector) serveAdmin(w http.ResponseWriter, r *http.Request) {
+	switch strings.TrimSuffix(r.URL.Path, "/") {
+	case "":
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			adminError(w, r, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		c.serveAdminStatus(w, r)
+	case "/health":
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			adminError(w, r, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		if err := c.CheckKillPool(r.Context()); err != nil {
+			adminError(w, r, http.StatusServiceUnavailable, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	case "/cancel":
+		if r.Method != http.MethodPost {
+			adminError(w, r, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		c.serveAdminCancel(w, r)
+	default:
+		adminError(w, r, http.StatusNotFound, "not found")
+	}
+}
+
+func (c *Connector) adminStatus() adminStatus {
+	var now = time.Now()
+	var status = adminStatus{Connector: c.name, Ready: c.KillPoolReady(), Queries: []adminQuery{}, Kills: []adminKill{}}
+
+	for _, q := range c.InFlight() {
+		var aq = adminQuery{
+			ID:           q.ID,
+			ConnectionID: q.ConnectionID,
+			Query:        q.Query,
+			Args:         q.Args,
+			Start:        q.Start,
+			Elapsed:      now.Sub(q.Start).Round(time.Millisecond).String(),
+			Labels:       q.Labels,
+		}
+		if !q.Deadline.IsZero() {
+			var deadline = q.Deadline
+			aq.Deadline = &deadline
+		}
+		status.Queries = append(status.Queries, aq)
+	}
+
+	var stats = c.killer.pool.Stats()
+	status.KillPool = adminKillPool{
+		MaxOpenConnections: stats.MaxOpenConnections,
+		OpenConnections:    stats.OpenConnections,
+		InUse:              stats.InUse,
+		Idle:               stats.Idle,
+		WaitCount:          stats.WaitCount,
+		WaitDuration:       stats.WaitDuration.String(),
+	}
+
+	for _, k := range c.killer.history.snapshot() {
+		var ak = adminKill{
+			Time:         k.Time,
+			Event:        k.Event,
+			ConnectionID: k.ConnectionID,
+			Query:        k.Query,
+			Statement:    k.Statement,
+			Path:         k.Path,
+		}
+		if k.Latency > 0 {
+			ak.Latency = k.Latency.String()
+		}
+		if k.Cause != nil {
+			ak.Cause = k.Cause.Error()
+		}
+		if k.Err != nil {
+			ak.Error = k.Err.Error()
+		}
+		status.Kills = append(status.Kills, ak)
+	}
+	return status
+}
+
+func (c *Connector) serveAdminStatus(w http.ResponseWriter, r *http.Request) {
+	var status = c.adminStatus()
+	if wantsHTML(r) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		adminTemplate.Execute(w, status)
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+func (c *Connector) serveAdminCancel(w http.ResponseWriter, r *http.Request) {
+	var id, err = strconv.ParseUint(r.FormValue("id"), 10, 64)
+	if err != nil {
+		adminError(w, r, http.StatusBadRequest, "invalid query id")
+		return
+	}
+
+	switch err = c.Cancel(QueryID(id)); {
+	case err == ErrQueryNotFound:
+		adminError(w, r, http.StatusNotFound, err.Error())
+	case err != nil:
+		adminError(w, r, http.StatusConflict, err.Error())
+	case wantsHTML(r):
+		http.Redirect(w, r, "./", http.StatusSeeOther)
+	default:
+		writeJSON(w, http.StatusOK, map[string]QueryID{"cancelled": QueryID(id)})
+	}
+}
+
+// wantsHTML reports whether r comes from a browser.
+func wantsHTML(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "text/html")
+}
+
+func adminError(w http.ResponseWriter, r *http.Request, code int, msg string) {
+	if wantsHTML(r) {
+		http.Error(w, msg, code)
+		return
+	}
+	writeJSON(w, code, map[string]string{"error": msg})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+var adminTemplate = template.Must(template.New("admin").Parse(`<!DOCTYPE html>
+<html>
+<head><title>{{.Connector}} queries</title></head>
+<body>
+<h1>{{.Connector}}</h1>
+<h2>In-flight queries</h2>
+<table border="1">
+<tr><th>ID</th><th>Connection</th><th>Query</th><th>Args</th><th>Elapsed</th><th>Deadline</th><th>Labels</th><th></th></tr>
+{{range .Queries}}<tr>
+<td>{{.ID}}</td><td>{{.ConnectionID}}</td><td><code>{{.Query}}</code></td><td>{{.Args}}</td><td>{{.Elapsed}}</td>
+<td>{{if .Deadline}}{{.Deadline}}{{end}}</td><td>{{range $k, $v := .Labels}}{{$k}}={{$v}} {{end}}</td>
+<td><form method="post" action="cancel"><input type="hidden" name="id" value="{{.ID}}"><button>Cancel</button></form></td>
+</tr>{{end}}
+</table>
+<h2>Kill pool</h2>
+<p>{{if .Ready}}Ready{{else}}Not ready{{end}}: {{.KillPool.InUse}} in use, {{.KillPool.Idle}} idle of {{.KillPool.MaxOpenConnections}};
+{{.KillPool.WaitCount}} waits for {{.KillPool.WaitDuration}}</p>
+<h2>Recent kills</h2>
+<table border="1">
+<tr><th>Time</th><th>Event</th><th>Connection</th><th>Query</th><th>Statement</th><th>Path</th><th>Latency</th><th>Cause</th><th>Error</th></tr>
+{{range .Kills}}<tr>
+<td>{{.Time.Format "2006-01-02 15:04:05.000"}}</td><td>{{.Event}}</td><td>{{.ConnectionID}}</td><td><code>{{.Query}}</code></td>
+<td><code>{{.Statement}}</code></td><td>{{.Path}}</td><td>{{.Latency}}</td><td>{{.Cause}}</td><td>{{.Error}}</td>
+</tr>{{end}}
+</table>
+</body>
+</html>
+`))
@@ -0,0 +1,76 @@
+package sql
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestAdminHandler(t *testing.T) {
+	var server = newFakeServer()
+	var c = newTestConnector(t, server)
+	var conn = connect(t, c)
+
+	var errs = make(chan error, 1)
+	go func() {
+		var _, err = conn.ExecContext(context.Background(), "SLEEP", nil)
+		errs <- err
+	}()
+	var q = awaitInFlight(t, c, 1)[0]
+
+	var authorized bool
+	var handler = c.AdminHandler(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "secret" {
+				http.Error(w, "forbidden", http.StatusForbidden)
+				return
+			}
+			authorized = true
+			next.ServeHTTP(w, r)
+		})
+	})
+
+	var rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusForbidden || authorized {
+		t.Fatalf("unauthorized request served with %d", rec.Code)
+	}
+
+	var req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "secret")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	var status adminStatus
+	if err := json.NewDecoder(rec.Body).Decode(&status); err != nil {
+		t.Fatal(err)
+	}
+	if len(status.Queries) != 1 || status.Queries[0].ID != q.ID {
+		t.Errorf("queries = %+v, want query %d", status.Queries, q.ID)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/cancel", strings.NewReader(url.Values{"id": {strconv.FormatUint(uint64(q.ID), 10)}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "secret")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("cancel = %d %s", rec.Code, rec.Body)
+	}
+	if err := <-errs; !isInterrupted(err) {
+		t.Errorf("err = %v, want the interrupted statement", err)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "secret")
+	req.Header.Set("Accept", "text/html")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if !strings.Contains(rec.Body.String(), "KILL QUERY "+conn.connectionID.String()) {
+		t.Errorf("kill missing from the recent kills:\n%s", rec.Body)
+	}
+}
@@ -0,0 +1,78 @@
+package sql
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// CancelError is returned by a query whose context was done while it ran.
+// It wraps the error of the context, its cause and the error the query
+// returned, so errors.Is(err, context.Canceled) and errors.As with a
+// *mysql.MySQLError both work.
+type CancelError struct {
+	ConnectionID ConnectionID
+	// Killed reports whether the query was killed on the server.
+	Killed bool
+	// KillLatency is how long the kill took, zero if none was sent.
+	KillLatency time.Duration
+	// KillErr is the error of the kill, if it failed or was skipped.
+	// It wraps ErrConnectionClosed if the socket was closed instead.
+	KillErr error
+	// KillPath is the way the kill reached the server, or KillPathClose
+	// if the socket of the connection was closed instead.
+	KillPath KillPath
+	// Err is the error of the context: context.Canceled or
+	// context.DeadlineExceeded.
+	Err error
+	// Cause is context.Cause of the context.
+	Cause error
+	// Timeout is the timeout of the statement class which set the deadline
+	// of the context, zero if the caller did.
+	Timeout time.Duration
+	// QueryErr is the error the query returned, such as the MySQL error
+	// 1317 of a killed statement, if it differs from Err.
+	QueryErr error
+}
+
+func (e *CancelError) Error() string {
+	var kill string
+	switch {
+	case e.Killed:
+		kill = fmt.Sprintf("killed in %s", e.KillLatency)
+	case e.KillErr != nil:
+		kill = fmt.Sprintf("not killed: %v", e.KillErr)
+	default:
+		kill = "not killed"
+	}
+
+	var cause = e.Err
+	if e.Cause != nil {
+		cause = e.Cause
+	}
+	if e.QueryErr != nil {
+		return fmt.Sprintf("sql: query on connection %s canceled (%s): %v: %v", e.ConnectionID, kill, cause, e.QueryErr)
+	}
+	return fmt.Sprintf("sql: query on connection %s canceled (%s): %v", e.ConnectionID, kill, cause)
+}
+
+func (e *CancelError) Unwrap() []error {
+	var errs = []error{e.Err}
+	if e.Cause != nil && e.Cause != e.Err {
+		errs = append(errs, e.Cause)
+	}
+	if e.QueryErr != nil {
+		errs = append(errs, e.QueryErr)
+	}
+	return errs
+}
+
+// cancelError returns the error of a query on the connection which
+// returned queryErr after ctx was done. The caller sets the kill fields.
+func (c *cancellableMysqlConn) cancelError(ctx context.Context, queryErr error) *CancelError {
+	var e = &CancelError{ConnectionID: c.connectionID, Err: ctx.Err(), Cause: context.Cause(ctx), Timeout: contextTimeout(ctx)}
+	if queryErr != e.Err {
+		e.QueryErr = queryErr
+	}
+	return e
+}
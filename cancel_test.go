@@ -0,0 +1,85 @@
+package sql
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCancelError(t *testing.T) {
+	var server = newFakeServer()
+	var conn = connect(t, newTestConnector(t, server))
+
+	var errDeploy = errors.New("deploy")
+	var ctx, cancel = context.WithCancelCause(context.Background())
+	time.AfterFunc(10*time.Millisecond, func() { cancel(errDeploy) })
+
+	var _, err = conn.ExecContext(ctx, "SLEEP", nil)
+	var cerr *CancelError
+	if !errors.As(err, &cerr) {
+		t.Fatalf("err = %v, want a *CancelError", err)
+	}
+	if cerr.ConnectionID != conn.connectionID || !cerr.Killed || cerr.KillLatency <= 0 || cerr.KillErr != nil {
+		t.Errorf("CancelError = %+v, want the killed query of connection %s", cerr, conn.connectionID)
+	}
+	if !errors.Is(err, context.Canceled) || !errors.Is(err, errDeploy) || !isInterrupted(err) {
+		t.Errorf("err = %v, want the context error, its cause and the interrupted statement", err)
+	}
+}
+
+func TestCancelErrorQuery(t *testing.T) {
+	var server = newFakeServer()
+	var conn = connect(t, newTestConnector(t, server))
+
+	var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	var _, err = conn.QueryContext(ctx, "SLEEP", nil)
+	var cerr *CancelError
+	if !errors.As(err, &cerr) || !cerr.Killed {
+		t.Fatalf("err = %v, want a *CancelError of a killed query", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestCancelErrorRows(t *testing.T) {
+	var server = newFakeServer()
+	var c = newTestConnector(t, server)
+	var conn = connect(t, c)
+
+	var ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	var rows, err = conn.QueryContext(ctx, "SELECT n FROM STREAM", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var dest = make([]driver.Value, 1)
+	for i := 0; i < 10; i++ {
+		if err = rows.Next(dest); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cancel()
+
+	var cerr *CancelError
+	if err = rows.Next(dest); !errors.As(err, &cerr) || !cerr.Killed {
+		t.Fatalf("Next = %v, want a *CancelError of a killed query", err)
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want %v", err, context.Canceled)
+	}
+	if kills := server.killStatements(); len(kills) != 1 {
+		t.Errorf("kills = %v, want one", kills)
+	}
+
+	rows.Close()
+	awaitInFlight(t, c, 0)
+	if kills := server.killStatements(); len(kills) != 1 {
+		t.Errorf("kills after Close = %v, want one", kills)
+	}
+}
@@ -4,33 +4,68 @@ package sql
 
 import (
 	"context"
-	"database/sql"
 	"database/sql/driver"
-	"github.com/go-sql-driver/mysql"
-	"io/ioutil"
-	"log"
-	"time"
+	"net"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/trace"
 )
 
 type cancellableMysqlConn struct {
 	conn         driver.Conn
-	killerPool   *sql.DB
-	connectionID string
-	kto          time.Duration
+	connector    *Connector
+	connectionID ConnectionID
+	bad          int32 // accessed atomically
+	flavor       Flavor
+	netConn      net.Conn // nil if the socket is not known
 }
 
-func new_cancellableMySQLConn(conn driver.Conn, db *sql.DB, ConnectionID string, kto time.Duration) *cancellableMysqlConn{
-	if DebugMode {
-		_ = mysql.SetLogger(log.New(ioutil.Discard, "", 0))
-		log.Printf("New connection %s created!", ConnectionID)
-	}
-	return &cancellableMysqlConn{conn, db, ConnectionID, kto}
+func new_cancellableMySQLConn(conn driver.Conn, connector *Connector, ConnectionID ConnectionID, flavor Flavor) *cancellableMysqlConn {
+	return &cancellableMysqlConn{conn: conn, connector: connector, connectionID: ConnectionID, flavor: flavor}
+}
+
+// markBad makes database/sql discard the connection instead of reusing it.
+func (c *cancellableMysqlConn) markBad() {
+	atomic.StoreInt32(&c.bad, 1)
+}
+
+func (c *cancellableMysqlConn) isBad() bool {
+	return atomic.LoadInt32(&c.bad) == 1
 }
 
+// Unleak will release the reference to the connector
+// in order to prevent a memory leak.
 func (c *cancellableMysqlConn) Unleak() {
-	c.killerPool = nil
-	c.connectionID = ""
+	c.connector = nil
+	c.connectionID = 0
+}
+
+// kill kills the query running on the connection, and calls abort, if
+// set, when the kill fails. It does nothing once the connection is closed.
+func (c *cancellableMysqlConn) kill(ctx context.Context, info *QueryInfo, abort func() error) (KillPath, error) {
+	if c == nil || c.connector == nil {
+		return "", nil
+	}
+	return c.connector.kill(ctx, c, info, abort)
+}
+
+// skips reports whether the wrapped driver would refuse to execute a
+// statement with args directly, which it does unless interpolateParams is
+// set. The statement is then left to database/sql to prepare, without
+// running the hooks twice.
+func (c *cancellableMysqlConn) skips(args []driver.NamedValue) bool {
+	return len(args) != 0 && !c.connector.cfg.InterpolateParams
+}
+
+// mark returns a marker for a statement of the connection, or the
+// empty string if kills are not verified.
+func (c *cancellableMysqlConn) mark() string {
+	if c.connectionID == 0 || !c.connector.killer.verify {
+		return ""
+	}
+	return c.connector.nextMarker()
 }
+
 func (c *cancellableMysqlConn) Ping(ctx context.Context) error {
 	var connPinger = c.conn.(driver.Pinger)
 
@@ -39,72 +74,184 @@ func (c *cancellableMysqlConn) Ping(ctx context.Context) error {
 	return connPinger.Ping(ctx)
 }
 
+// Exec can not be canceled, but the watchdog can kill it.
 func (c *cancellableMysqlConn) Exec(query string, args []driver.Value) (driver.Result, error) {
-	var execer = c.conn.(driver.Execer)
-	return execer.Exec(query, args)
+	var execerContext = c.conn.(driver.ExecerContext)
+
+	var info = &QueryInfo{Query: query, Args: namedValues(args), ConnectionID: c.connectionID, marker: c.mark()}
+	if err := c.connector.queries.enter(context.Background(), c, info); err != nil {
+		return nil, err
+	}
+	defer c.connector.queries.leave(info)
+	var w, err = c.watch(context.Background(), info)
+	if err != nil {
+		return nil, err
+	}
+	defer w.done()
+
+	return execerContext.ExecContext(w.driverCtx, markQuery(query, info.marker), info.Args)
 }
 
-func (c *cancellableMysqlConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+func (c *cancellableMysqlConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (res driver.Result, err error) {
 	var execerContext = c.conn.(driver.ExecerContext)
+	if c.skips(args) {
+		return nil, driver.ErrSkip
+	}
 
-	// Create a context that is used to cancel ExecContext()
-	cancelCtx, cancelFunc := context.WithCancel(context.Background())
+	var cancelFunc context.CancelFunc
+	ctx, cancelFunc = c.connector.withQueryTimeout(ctx, query)
 	defer cancelFunc()
 
-	outChan := make(chan sql.Result)
-	errChan := make(chan error)
-	returnedChan := make(chan struct{}) // Used to indicate that this function has returned
+	var info = &QueryInfo{Query: query, Args: args, ConnectionID: c.connectionID, marker: c.mark()}
+	if err = c.connector.interceptors.beforeExec(ctx, info); err != nil {
+		return nil, err
+	}
+	query, args = info.Query, info.Args
+	defer func() { c.connector.interceptors.afterExec(ctx, info, err) }()
 
-	defer func() {
-		returnedChan <- struct{}{}
-	}()
+	if err = c.connector.queries.enter(ctx, c, info); err != nil {
+		return nil, err
+	}
+	defer c.connector.queries.leave(info)
 
-	go func() {
-		select {
-		case <-ctx.Done():
-			// context has been canceled
-			kill(c.killerPool, c.connectionID, c.kto)
-			errChan <- ctx.Err()
-		case <-returnedChan:
+	var span trace.Span
+	ctx, span = startSpan(ctx, c.connector.tracer, "mysqlc.exec", c.connectionID, query)
+	defer func() {
+		if err == driver.ErrSkip {
+			// Not an error: database/sql prepares the statement instead.
+			endSpan(span, nil)
+			return
 		}
+		endSpan(span, err)
 	}()
 
-	go func() {
-		res, err := execerContext.ExecContext(cancelCtx, query, args)
-		if err != nil {
-			errChan <- err
-			return
+	if c.connectionID == 0 {
+		// Without a connection ID the query can not be killed;
+		// leave the cancellation to the wrapped driver.
+		res, err = execerContext.ExecContext(ctx, query, args)
+		if err != nil && ctx.Err() != nil {
+			return nil, c.cancelError(ctx, err)
 		}
-		outChan <- res
-	}()
+		return res, err
+	}
 
-	select {
-	case err := <-errChan:
+	// The wrapped driver is not given ctx, as it would close the connection
+	// on cancellation while the query is still running on the server. The
+	// watch kills the query instead, which makes the wrapped driver return,
+	// and only cancels the context of the wrapped driver if the kill fails.
+	var w *killWatch
+	if w, err = c.watch(ctx, info); err != nil {
 		return nil, err
-	case out := <-outChan:
-		return out, nil
 	}
+	res, err = execerContext.ExecContext(w.driverCtx, markQuery(query, info.marker), args)
+	if w.done() && err != nil {
+		return nil, w.cancelError(ctx, c, err)
+	}
+	return res, err
 }
 
+// Query can not be canceled, but the watchdog can kill it.
 func (c *cancellableMysqlConn) Query(query string, args []driver.Value) (driver.Rows, error) {
-	var queryer = c.conn.(driver.Queryer)
-	return queryer.Query(query, args)
+	var queryerContext = c.conn.(driver.QueryerContext)
+
+	var info = &QueryInfo{Query: query, Args: namedValues(args), ConnectionID: c.connectionID, marker: c.mark()}
+	if err := c.connector.queries.enter(context.Background(), c, info); err != nil {
+		return nil, err
+	}
+
+	var w, err = c.watch(context.Background(), info)
+	if err != nil {
+		c.connector.queries.leave(info)
+		return nil, err
+	}
+	var rows driver.Rows
+	if rows, err = queryerContext.QueryContext(w.driverCtx, markQuery(query, info.marker), info.Args); err != nil {
+		w.done()
+		c.connector.queries.leave(info)
+		return nil, err
+	}
+	return &cancellableMysqlRows{ctx: context.Background(), rows: rows, conn: c, info: info, watch: w}, nil
 }
 
-func (c *cancellableMysqlConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+// namedValues converts the arguments of the context-less methods.
+func namedValues(args []driver.Value) []driver.NamedValue {
+	var named = make([]driver.NamedValue, len(args))
+	for i, v := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: v}
+	}
+	return named
+}
+
+func (c *cancellableMysqlConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (_ driver.Rows, err error) {
 	var queryerContext = c.conn.(driver.QueryerContext)
+	if c.skips(args) {
+		return nil, driver.ErrSkip
+	}
 
-	// We can't use the same approach used in ExecContext because defer cancelFunc()
-	// cancels rows.Scan.
+	// The deadline of the statement class lasts until the rows are closed.
+	var cancelFunc context.CancelFunc
+	ctx, cancelFunc = c.connector.withQueryTimeout(ctx, query)
 	defer func() {
-		if ctx.Err() != nil {
-			kill(c.killerPool, c.connectionID, c.kto)
+		if err != nil {
+			cancelFunc()
+		}
+	}()
+
+	var info = &QueryInfo{Query: query, Args: args, ConnectionID: c.connectionID, marker: c.mark()}
+	if err = c.connector.interceptors.beforeQuery(ctx, info); err != nil {
+		return nil, err
+	}
+	query, args = info.Query, info.Args
+	defer func() { c.connector.interceptors.afterQuery(ctx, info, err) }()
 
+	// The query leaves the registry when its rows are closed.
+	if err = c.connector.queries.enter(ctx, c, info); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			c.connector.queries.leave(info)
 		}
 	}()
 
-	rows, err := queryerContext.QueryContext(ctx, query, args)
-	return &cancellableMysqlRows{ctx: ctx, rows: rows, killerPool: c.killerPool, connectionID: c.connectionID}, err
+	var span trace.Span
+	ctx, span = startSpan(ctx, c.connector.tracer, "mysqlc.query", c.connectionID, query)
+	defer func() {
+		if err == driver.ErrSkip {
+			// Not an error: database/sql prepares the statement instead.
+			endSpan(span, nil)
+			return
+		}
+		endSpan(span, err)
+	}()
+
+	var rows driver.Rows
+	query = markQuery(c.hint(ctx, query), info.marker)
+	if c.connectionID == 0 {
+		// Without a connection ID the query can not be killed;
+		// leave the cancellation to the wrapped driver.
+		if rows, err = queryerContext.QueryContext(ctx, query, args); err != nil {
+			if ctx.Err() != nil {
+				return nil, c.cancelError(ctx, err)
+			}
+			return nil, err
+		}
+		return &cancellableMysqlRows{ctx: ctx, cancelFunc: cancelFunc, rows: rows, conn: c, info: info}, nil
+	}
+
+	// See ExecContext. The rows keep the watch while they are read,
+	// and end it when they are closed.
+	var w *killWatch
+	if w, err = c.watch(ctx, info); err != nil {
+		return nil, err
+	}
+	if rows, err = queryerContext.QueryContext(w.driverCtx, query, args); err != nil {
+		if w.done() {
+			return nil, w.cancelError(ctx, c, err)
+		}
+		return nil, err
+	}
+	return &cancellableMysqlRows{ctx: ctx, cancelFunc: cancelFunc, rows: rows, conn: c, info: info, watch: w}, nil
 }
 
 func (c *cancellableMysqlConn) Prepare(query string) (driver.Stmt, error) {
@@ -127,36 +274,74 @@ func (c *cancellableMysqlConn) Begin() (driver.Tx, error) {
 func (c *cancellableMysqlConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
 	var connPrepareContext = c.conn.(driver.ConnPrepareContext)
 
+	var info = &QueryInfo{Query: query, ConnectionID: c.connectionID, marker: c.mark()}
+	if err := c.connector.interceptors.onPrepare(ctx, info); err != nil {
+		return nil, err
+	}
+	query = info.Query
+
 	// You can not cancel a Prepare.
 	// See: https://github.com/rocketlaunchr/mysql-go/issues/3
-	stmt, err := connPrepareContext.PrepareContext(ctx, query)
+	stmt, err := connPrepareContext.PrepareContext(ctx, markQuery(query, info.marker))
 	if err != nil {
 		return nil, err
 	}
-	return &cancellableMysqlStfmt{stmt, c.killerPool, c.connectionID, c.kto}, nil
+	return &cancellableMysqlStfmt{stmt: stmt, conn: c, query: query, marker: info.marker}, nil
 }
 
 func (c *cancellableMysqlConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
 	var connBeginTx = c.conn.(driver.ConnBeginTx)
-	return connBeginTx.BeginTx(ctx, opts)
+
+	if err := c.connector.interceptors.onBeginTx(ctx, &QueryInfo{ConnectionID: c.connectionID}, opts); err != nil {
+		return nil, err
+	}
+
+	tx, err := connBeginTx.BeginTx(ctx, opts)
+	if err != nil || c.connectionID == 0 || ctx.Done() == nil {
+		return tx, err
+	}
+	return &cancellableMysqlTx{tx: tx, ctx: ctx, conn: c}, nil
 }
 
+// ResetSession implements driver.SessionResetter.
+// It is called by database/sql before a connection is reused.
 func (c *cancellableMysqlConn) ResetSession(ctx context.Context) error {
-	var sessionResetter = c.conn.(driver.SessionResetter)
-	return sessionResetter.ResetSession(ctx)
+	if c.isBad() {
+		return driver.ErrBadConn
+	}
+
+	if sessionResetter, ok := c.conn.(driver.SessionResetter); ok {
+		if err := sessionResetter.ResetSession(ctx); err != nil {
+			return err
+		}
+	}
+
+	if c.connector != nil && c.connector.cfg.pingOnReuse {
+		if err := c.Ping(ctx); err != nil {
+			c.markBad()
+			return driver.ErrBadConn
+		}
+	}
+	return nil
 }
 
-/*
+// IsValid implements driver.Validator.
+// A connection a query was killed on is not valid.
 func (c *cancellableMysqlConn) IsValid() bool {
-	var validator = c.conn.(driver.Validator)
-	return validator.IsValid()
+	if c.isBad() {
+		return false
+	}
+
+	if validator, ok := c.conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
 }
-*/
 
 func (c *cancellableMysqlConn) CheckNamedValue(nv *driver.NamedValue) (err error) {
 	var namedValueChecker = c.conn.(driver.NamedValueChecker)
 	return namedValueChecker.CheckNamedValue(nv)
 }
-func Bench(){
+func Bench() {
 
 }
@@ -0,0 +1,40 @@
+package sql
+
+import (
+	"context"
+	"testing"
+)
+
+func BenchmarkExecContext(b *testing.B) {
+	var conn = connect(b, newTestConnector(b, newFakeServer()))
+	var ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := conn.ExecContext(ctx, "DO 1", nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkStmtExecContext(b *testing.B) {
+	var conn = connect(b, newTestConnector(b, newFakeServer()))
+	var ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+
+	var stmt, err = conn.PrepareContext(ctx, "DO 1")
+	if err != nil {
+		b.Fatal(err)
+	}
+	var execer = stmt.(*cancellableMysqlStfmt)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := execer.ExecContext(ctx, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"github.com/KyleBanks/dockerstats"
 	"github.com/go-sql-driver/mysql"
@@ -105,7 +106,7 @@ func foo() {
 		_, err = dbStd.QueryContext(queryctx, MediumQuery)
 		//5 sec
 	}
-	if err != context.DeadlineExceeded && err != nil {
+	if !errors.Is(err, context.DeadlineExceeded) && err != nil {
 		log.Fatal(err)
 	}
 
@@ -281,7 +282,7 @@ func calculationPart(dbStd *sql.DB) plotter.XYs {
 					queryctx, querycancel := context.WithTimeout(context.Background(), 15*time.Second)
 					defer querycancel()
 					fakeRows, err = dbStd.QueryContext(queryctx, HardQuery)
-					if err != nil && err != context.DeadlineExceeded {
+					if err != nil && !errors.Is(err, context.DeadlineExceeded) {
 						log.Fatal("got error in hardquery:", err)
 					}
 					fmt.Println("hard query done")
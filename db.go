@@ -6,7 +6,13 @@ import (
 	"context"
 	"database/sql"
 	"database/sql/driver"
+	"fmt"
 	"github.com/go-sql-driver/mysql"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -14,14 +20,25 @@ const (
 	cancellableDriverName = "mysqlc"
 	defaultKillPoolSize   = 1
 	defaultKillTimeout    = 5 * time.Second
+	defaultKillGrace      = time.Second
+	minKillPollInterval   = 10 * time.Millisecond
+
+	defaultKillOnEarlyCloseRows  = 100
+	defaultKillOnEarlyCloseBytes = 64 << 10
 )
 
 type CancellableMySQLDriver struct{}
 
 var originalDriver = mysql.MySQLDriver{}
 
+// CancelModeUsage is the default of the cancelMode DSN parameter.
+//
+// Deprecated: use the cancelMode DSN parameter or WithCancelMode.
 var CancelModeUsage bool
 
+// DebugMode is the default of the debug DSN parameter.
+//
+// Deprecated: use the debug DSN parameter or WithDebug.
 var DebugMode bool
 
 func init() {
@@ -47,57 +64,311 @@ func (d CancellableMySQLDriver) OpenConnector(dsn string) (driver.Connector, err
 		return nil, err
 	}
 
-	var connector driver.Connector
-	if connector, err = originalDriver.OpenConnector(dsn); err != nil {
+	return NewConnector(cfg)
+}
+
+// Option configures a Connector.
+type Option func(*Connector)
+
+// WithCancelMode enables or disables killing queries on context cancellation.
+func WithCancelMode(enabled bool) Option {
+	return func(c *Connector) {
+		c.cfg.cancelMode = enabled
+	}
+}
+
+// WithDebug enables or disables debug output to the standard logger.
+// It has no effect if a logger is set with WithLogger.
+func WithDebug(enabled bool) Option {
+	return func(c *Connector) {
+		c.cfg.debug = enabled
+	}
+}
+
+// WithPingOnReuse enables or disables pinging connections before
+// database/sql reuses them. Connections which fail the ping are discarded.
+func WithPingOnReuse(enabled bool) Option {
+	return func(c *Connector) {
+		c.cfg.pingOnReuse = enabled
+	}
+}
+
+// WithExecutionTimeHint enables or disables limiting the execution time of
+// SELECTs on the server to the time left until the context deadline.
+func WithExecutionTimeHint(enabled bool) Option {
+	return func(c *Connector) {
+		c.cfg.executionTimeHint = enabled
+	}
+}
+
+// WithFlavor sets the flavor of the server, instead of detecting it
+// from the server version when a connection is opened.
+func WithFlavor(f Flavor) Option {
+	return func(c *Connector) {
+		c.flavor = f
+	}
+}
+
+// WithKillVerify enables or disables confirming that a query is still
+// running before killing it.
+func WithKillVerify(enabled bool) Option {
+	return func(c *Connector) {
+		c.cfg.killVerify = enabled
+	}
+}
+
+// WithKillOnEarlyClose enables killing the query of rows closed before
+// they were all read, instead of draining them, once more than rows rows
+// or bytes bytes are left. Values not above zero keep the defaults.
+func WithKillOnEarlyClose(rows, bytes int) Option {
+	return func(c *Connector) {
+		c.cfg.killOnEarlyClose = true
+		if rows > 0 {
+			c.cfg.killOnEarlyCloseRows = rows
+		}
+		if bytes > 0 {
+			c.cfg.killOnEarlyCloseBytes = bytes
+		}
+	}
+}
+
+// WithKillMode sets the statement used to kill queries.
+func WithKillMode(mode KillMode) Option {
+	return func(c *Connector) {
+		c.cfg.killMode = mode
+	}
+}
+
+// WithKillGrace sets the time the KillEscalate mode waits for KILL QUERY to take effect.
+func WithKillGrace(grace time.Duration) Option {
+	return func(c *Connector) {
+		c.cfg.killGrace = grace
+	}
+}
+
+// WithKillTimeout sets the timeout of kill operations.
+func WithKillTimeout(timeout time.Duration) Option {
+	return func(c *Connector) {
+		c.cfg.killTimeout = timeout
+	}
+}
+
+// WithKillPoolSize sets the size of the connection pool used for killing queries.
+func WithKillPoolSize(size int) Option {
+	return func(c *Connector) {
+		c.cfg.killPoolSize = size
+	}
+}
+
+// Connector is a driver.Connector which kills the queries of its
+// connections when their context is canceled.
+// It can be used with sql.OpenDB.
+type Connector struct {
+	cfg       *Config
+	connector driver.Connector
+	killer    *killer
+	logger    Logger
+	metrics   MetricsSink
+	name      string
+	tracer    trace.Tracer
+	flavor    Flavor
+
+	interceptors interceptorChain
+
+	queries       registry
+	redactor      Redactor
+	classTimeouts map[StatementClass]time.Duration
+
+	markers uint64 // accessed atomically
+
+	// unobserve unregisters the kill pool from the KillPoolObserver.
+	unobserve func()
+	closeOnce sync.Once
+}
+
+// NewConnector returns a Connector for cfg.
+// Options take precedence over the settings in cfg.
+func NewConnector(cfg *Config, opts ...Option) (*Connector, error) {
+	var c = &Connector{
+		cfg: cfg.Clone(),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.logger == nil {
+		if c.cfg.debug {
+			c.logger = NewStdLogger(nil)
+		} else {
+			c.logger = nopLogger{}
+		}
+	}
+
+	if c.metrics == nil {
+		c.metrics = nopMetrics{}
+	}
+	if c.name == "" {
+		c.name = c.cfg.Addr
+	}
+	if c.tracer == nil {
+		c.tracer = trace.NewNoopTracerProvider().Tracer(tracerName)
+	}
+
+	var err error
+	if c.flavor == nil && c.cfg.flavor != "" {
+		if c.flavor, err = lookupFlavor(c.cfg.flavor); err != nil {
+			return nil, err
+		}
+	}
+
+	if c.connector, err = mysql.NewConnector(dataConfig(&c.cfg.Config)); err != nil {
 		return nil, err
 	}
 
-	var killConnector driver.Connector
-	if killConnector, err = originalDriver.OpenConnector(dsn); err != nil {
+	var killCfg *mysql.Config
+	if killCfg, err = c.cfg.killConfig(); err != nil {
 		return nil, err
 	}
 
-	var killPool = sql.OpenDB(killConnector)
-	killPool.SetMaxOpenConns(cfg.killPoolSize)
-	return &cancellableConnector{
-		connector:   connector,
-		killPool:    killPool,
-		killTimeout: cfg.killTimeout,
-	}, nil
+	var pool *killPool
+	if pool, err = acquireKillPool(killCfg, c.cfg.killPoolSize, c.cfg.killPoolPing); err != nil {
+		return nil, err
+	}
+	c.killer = &killer{
+		pool:    pool,
+		timeout: c.cfg.killTimeout,
+		mode:    c.cfg.killMode,
+		grace:   c.cfg.killGrace,
+		logger:  c.logger,
+		metrics: c.metrics,
+		name:    c.name,
+		tracer:  c.tracer,
+		verify:  c.cfg.killVerify,
+		history: &killHistory{},
+	}
+	if interval := c.watchdogInterval(); interval > 0 {
+		c.queries.watchdog = func() { c.runWatchdog(interval) }
+	}
+
+	if observer, ok := c.metrics.(KillPoolObserver); ok {
+		c.unobserve = observer.ObserveKillPool(c.name, pool.Stats)
+	}
+	return c, nil
 }
 
-type cancellableConnector struct {
-	connector   driver.Connector
-	killPool    *sql.DB
-	killTimeout time.Duration
+// nextMarker returns a comment identifying a statement.
+func (c *Connector) nextMarker() string {
+	return "/*mysqlc:" + strconv.FormatUint(atomic.AddUint64(&c.markers, 1), 10) + "*/"
 }
 
-func (c *cancellableConnector) Connect(ctx context.Context) (driver.Conn, error) {
-	var conn, err = c.connector.Connect(ctx)
+// Connect implements driver.Connector interface.
+// Connect returns a connection to the database.
+func (c *Connector) Connect(ctx context.Context) (driver.Conn, error) {
+	var hctx, hs = withHandshake(ctx)
+	var conn, err = c.connector.Connect(hctx)
 	if err != nil {
 		return nil, err
 	}
 
-	// Determine the connection's connection_id
-	var connectionID string
-	if(CancelModeUsage){
-		if connectionID, err = determineConnectionId(ctx, conn); err != nil {
-			conn.Close()
-			return nil, err
+	var flavor, ferr = c.connFlavor(ctx, conn, hs)
+	if ferr != nil {
+		conn.Close()
+		return nil, ferr
+	}
+
+	// Determine the connection's connection_id, unless the server sent
+	// it in the handshake
+	var connectionID ConnectionID
+	if c.cfg.cancelMode {
+		c.killer.pool.reserve.warm()
+		c.killer.startKeepalive()
+
+		if hs.ok {
+			connectionID = hs.connectionID
+		} else {
+			connectionID, err = flavor.ConnectionID(ctx, conn)
+			c.metrics.ConnectionIDLookup(MetricLabels{Connector: c.name}, err)
+			if err != nil {
+				trace.SpanFromContext(ctx).AddEvent("mysqlc.connection_id_lookup_failed",
+					trace.WithAttributes(attribute.String("error", err.Error())))
+				conn.Close()
+				return nil, err
+			}
 		}
+		trace.SpanFromContext(ctx).AddEvent("mysqlc.connection_id_resolved",
+			trace.WithAttributes(
+				attribute.Int64("db.mysql.connection_id", int64(connectionID)),
+				attribute.String("mysqlc.flavor", flavor.Name()),
+				attribute.Bool("mysqlc.handshake", hs.ok),
+			))
+		c.logger.Log(ctx, LogEntry{Event: EventConnectionIDResolved, ConnectionID: connectionID})
 	}
 
-	if c.killPool == nil {
-		return new_cancellableMySQLConn(conn, c.killPool, connectionID, c.killTimeout), nil
+	c.logger.Log(ctx, LogEntry{Event: EventConnectionOpened, ConnectionID: connectionID})
+
+	var cc = new_cancellableMySQLConn(conn, c, connectionID, flavor)
+	cc.netConn = hs.netConn
+	return cc, nil
+}
+
+// connFlavor returns the flavor of conn: the configured one or, if the
+// connection is going to need it, the one detected from the server version
+// sent in the handshake or queried.
+func (c *Connector) connFlavor(ctx context.Context, conn driver.Conn, hs *handshake) (Flavor, error) {
+	if c.flavor != nil {
+		return c.flavor, nil
+	}
+	if !c.cfg.cancelMode && !c.cfg.executionTimeHint {
+		return FlavorMySQL, nil
 	}
-	return new_cancellableMySQLConn(conn, c.killPool, connectionID, c.killTimeout), nil
+	if hs.ok {
+		return detectFlavor(hs.version), nil
+	}
+
+	var version, err = determineServerVersion(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	return detectFlavor(version), nil
 }
 
-// Connect implements driver.Connector interface.
-// Connect returns a connection to the database.
+// kill kills the query described by info, running on conn, and returns
+// the path the kill took.
+// A connection a kill was attempted on is not reused, as it may be left
+// with a half-read packet stream or a killed session.
+// A kill skipped because the query had already finished leaves the
+// connection usable.
+//
+// If the kill statements could not be sent, abort closes the socket of
+// the connection as the last resort, so that the caller returns, and
+// ErrConnectionClosed is returned.
+func (c *Connector) kill(ctx context.Context, conn *cancellableMysqlConn, info *QueryInfo, abort func() error) (KillPath, error) {
+	var path, err = c.killer.kill(ctx, conn.flavor, info)
+	if info.ConnectionID != 0 && err != ErrQueryFinished {
+		conn.markBad()
+	}
+	if err != nil && err != ErrQueryFinished && info.ConnectionID != 0 && abort != nil {
+		if cerr := abort(); cerr == nil {
+			c.killer.record(ctx, LogEntry{
+				Event:        EventConnectionClosed,
+				ConnectionID: info.ConnectionID,
+				Query:        fingerprint(info.Query),
+				Cause:        contextCause(ctx),
+				Err:          err,
+				Path:         KillPathClose,
+			})
+			path, err = KillPathClose, fmt.Errorf("%w: %w", ErrConnectionClosed, err)
+		}
+	}
+	if info.ConnectionID != 0 && (path != "" || err != nil) {
+		// A kill was attempted.
+		c.interceptors.onKill(ctx, info, err)
+	}
+	return path, err
+}
 
 // Driver implements driver.Connector interface.
 // Driver returns &CancellableMySQLDriver{}.
-func (c *cancellableConnector) Driver() driver.Driver {
+func (c *Connector) Driver() driver.Driver {
 	return &CancellableMySQLDriver{}
 }
@@ -16,7 +16,32 @@ type Config struct {
 	mysql.Config
 
 	killPoolSize int
+	killPoolPing time.Duration
 	killTimeout  time.Duration
+	killMode     KillMode
+	killGrace    time.Duration
+	killVerify   bool
+	cancelMode   bool
+	debug        bool
+	pingOnReuse  bool
+
+	executionTimeHint bool
+	flavor            string
+	queryTimeout      time.Duration
+	selectTimeout     time.Duration
+	dmlTimeout        time.Duration
+	ddlTimeout        time.Duration
+
+	killOnEarlyClose      bool
+	killOnEarlyCloseRows  int
+	killOnEarlyCloseBytes int
+
+	// Overrides of the data connection settings for the kill pool.
+	killUser   string
+	killPasswd string
+	killNet    string
+	killAddr   string
+	killTLS    string
 }
 
 // NewConfig creates a new Config and sets default values.
@@ -26,7 +51,15 @@ func NewConfig() *Config {
 	return &Config{
 		Config:       *cfg,
 		killPoolSize: defaultKillPoolSize,
+		killPoolPing: defaultKillPoolPing,
 		killTimeout:  defaultKillTimeout,
+		killMode:     KillQuery,
+		killGrace:    defaultKillGrace,
+		cancelMode:   CancelModeUsage,
+		debug:        DebugMode,
+
+		killOnEarlyCloseRows:  defaultKillOnEarlyCloseRows,
+		killOnEarlyCloseBytes: defaultKillOnEarlyCloseBytes,
 	}
 }
 
@@ -36,7 +69,30 @@ func (cfg *Config) Clone() *Config {
 	return &Config{
 		Config:       *cp,
 		killPoolSize: cfg.killPoolSize,
+		killPoolPing: cfg.killPoolPing,
 		killTimeout:  cfg.killTimeout,
+		killMode:     cfg.killMode,
+		killGrace:    cfg.killGrace,
+		killVerify:   cfg.killVerify,
+		cancelMode:   cfg.cancelMode,
+		debug:        cfg.debug,
+		pingOnReuse:  cfg.pingOnReuse,
+		killUser:     cfg.killUser,
+		killPasswd:   cfg.killPasswd,
+		killNet:      cfg.killNet,
+		killAddr:     cfg.killAddr,
+		killTLS:      cfg.killTLS,
+
+		executionTimeHint: cfg.executionTimeHint,
+		flavor:            cfg.flavor,
+		queryTimeout:      cfg.queryTimeout,
+		selectTimeout:     cfg.selectTimeout,
+		dmlTimeout:        cfg.dmlTimeout,
+		ddlTimeout:        cfg.ddlTimeout,
+
+		killOnEarlyClose:      cfg.killOnEarlyClose,
+		killOnEarlyCloseRows:  cfg.killOnEarlyCloseRows,
+		killOnEarlyCloseBytes: cfg.killOnEarlyCloseBytes,
 	}
 }
 
@@ -81,10 +137,94 @@ func (cfg *Config) FormatDSN() string {
 		writeDSNParam(&buf, &hasParam, "killPoolSize", strconv.Itoa(cfg.killPoolSize))
 	}
 
+	if cfg.killPoolPing != defaultKillPoolPing {
+		writeDSNParam(&buf, &hasParam, "killPoolPing", cfg.killPoolPing.String())
+	}
+
 	if cfg.killTimeout > 0 {
 		writeDSNParam(&buf, &hasParam, "killTimeout", cfg.killTimeout.String())
 	}
 
+	if cfg.killMode != KillQuery {
+		writeDSNParam(&buf, &hasParam, "killMode", cfg.killMode.String())
+	}
+
+	if cfg.killGrace > 0 {
+		writeDSNParam(&buf, &hasParam, "killGrace", cfg.killGrace.String())
+	}
+
+	if cfg.killVerify {
+		writeDSNParam(&buf, &hasParam, "killVerify", "true")
+	}
+
+	if cfg.cancelMode {
+		writeDSNParam(&buf, &hasParam, "cancelMode", "true")
+	}
+
+	if cfg.debug {
+		writeDSNParam(&buf, &hasParam, "debug", "true")
+	}
+
+	if cfg.pingOnReuse {
+		writeDSNParam(&buf, &hasParam, "pingOnReuse", "true")
+	}
+
+	if cfg.executionTimeHint {
+		writeDSNParam(&buf, &hasParam, "executionTimeHint", "true")
+	}
+
+	if cfg.flavor != "" {
+		writeDSNParam(&buf, &hasParam, "flavor", cfg.flavor)
+	}
+
+	if cfg.queryTimeout > 0 {
+		writeDSNParam(&buf, &hasParam, "queryTimeout", cfg.queryTimeout.String())
+	}
+
+	if cfg.selectTimeout > 0 {
+		writeDSNParam(&buf, &hasParam, "selectTimeout", cfg.selectTimeout.String())
+	}
+
+	if cfg.dmlTimeout > 0 {
+		writeDSNParam(&buf, &hasParam, "dmlTimeout", cfg.dmlTimeout.String())
+	}
+
+	if cfg.ddlTimeout > 0 {
+		writeDSNParam(&buf, &hasParam, "ddlTimeout", cfg.ddlTimeout.String())
+	}
+
+	if cfg.killOnEarlyClose {
+		writeDSNParam(&buf, &hasParam, "killOnEarlyClose", "true")
+	}
+
+	if cfg.killOnEarlyCloseRows > 0 && cfg.killOnEarlyCloseRows != defaultKillOnEarlyCloseRows {
+		writeDSNParam(&buf, &hasParam, "killOnEarlyCloseRows", strconv.Itoa(cfg.killOnEarlyCloseRows))
+	}
+
+	if cfg.killOnEarlyCloseBytes > 0 && cfg.killOnEarlyCloseBytes != defaultKillOnEarlyCloseBytes {
+		writeDSNParam(&buf, &hasParam, "killOnEarlyCloseBytes", strconv.Itoa(cfg.killOnEarlyCloseBytes))
+	}
+
+	if cfg.killUser != "" {
+		writeDSNParam(&buf, &hasParam, "killUser", url.QueryEscape(cfg.killUser))
+	}
+
+	if cfg.killPasswd != "" {
+		writeDSNParam(&buf, &hasParam, "killPasswd", url.QueryEscape(cfg.killPasswd))
+	}
+
+	if cfg.killNet != "" {
+		writeDSNParam(&buf, &hasParam, "killNet", url.QueryEscape(cfg.killNet))
+	}
+
+	if cfg.killAddr != "" {
+		writeDSNParam(&buf, &hasParam, "killAddr", url.QueryEscape(cfg.killAddr))
+	}
+
+	if cfg.killTLS != "" {
+		writeDSNParam(&buf, &hasParam, "killTLS", url.QueryEscape(cfg.killTLS))
+	}
+
 	return buf.String()
 }
 
@@ -96,7 +236,10 @@ func ParseDSN(dsn string) (*Config, error) {
 	}
 
 	var cfg = Config{
-		Config: *mysqlCfg,
+		Config:       *mysqlCfg,
+		killPoolPing: defaultKillPoolPing,
+		cancelMode:   CancelModeUsage,
+		debug:        DebugMode,
 	}
 
 	for name, value := range mysqlCfg.Params {
@@ -106,13 +249,121 @@ func ParseDSN(dsn string) (*Config, error) {
 			if err != nil {
 				return nil, err
 			}
+		// interval of the kill pool keepalive
+		case "killPoolPing":
+			cfg.killPoolPing, err = time.ParseDuration(url.QueryEscape(value))
+			if err != nil {
+				return nil, err
+			}
 		// kill queries timeout
 		case "killTimeout":
 			cfg.killTimeout, err = time.ParseDuration(url.QueryEscape(value))
 			if err != nil {
 				return nil, err
 			}
+		// statement used to kill queries
+		case "killMode":
+			cfg.killMode, err = parseKillMode(value)
+			if err != nil {
+				return nil, err
+			}
+		// time to wait for KILL QUERY before escalating to KILL CONNECTION
+		case "killGrace":
+			cfg.killGrace, err = time.ParseDuration(url.QueryEscape(value))
+			if err != nil {
+				return nil, err
+			}
+		// confirm a query is still running before killing it
+		case "killVerify":
+			cfg.killVerify, err = strconv.ParseBool(value)
+			if err != nil {
+				return nil, err
+			}
+		// kill queries on context cancellation
+		case "cancelMode":
+			cfg.cancelMode, err = strconv.ParseBool(value)
+			if err != nil {
+				return nil, err
+			}
+		case "debug":
+			cfg.debug, err = strconv.ParseBool(value)
+			if err != nil {
+				return nil, err
+			}
+		// health check of connections taken from the pool
+		case "pingOnReuse":
+			cfg.pingOnReuse, err = strconv.ParseBool(value)
+			if err != nil {
+				return nil, err
+			}
+		// server-side limit of SELECTs from the context deadline
+		case "executionTimeHint":
+			cfg.executionTimeHint, err = strconv.ParseBool(value)
+			if err != nil {
+				return nil, err
+			}
+		// server flavor, instead of detecting it
+		case "flavor":
+			if _, err = lookupFlavor(value); err != nil {
+				return nil, err
+			}
+			cfg.flavor = value
+		// deadline of statements, and age at which the watchdog kills them
+		case "queryTimeout":
+			cfg.queryTimeout, err = time.ParseDuration(url.QueryEscape(value))
+			if err != nil {
+				return nil, err
+			}
+		// the same per statement class, overriding queryTimeout
+		case "selectTimeout":
+			cfg.selectTimeout, err = time.ParseDuration(url.QueryEscape(value))
+			if err != nil {
+				return nil, err
+			}
+		case "dmlTimeout":
+			cfg.dmlTimeout, err = time.ParseDuration(url.QueryEscape(value))
+			if err != nil {
+				return nil, err
+			}
+		case "ddlTimeout":
+			cfg.ddlTimeout, err = time.ParseDuration(url.QueryEscape(value))
+			if err != nil {
+				return nil, err
+			}
+		// kill unfinished rows on Close instead of draining them
+		case "killOnEarlyClose":
+			cfg.killOnEarlyClose, err = strconv.ParseBool(value)
+			if err != nil {
+				return nil, err
+			}
+		// rows and bytes drained by Close before the query is killed
+		case "killOnEarlyCloseRows":
+			cfg.killOnEarlyCloseRows, err = strconv.Atoi(url.QueryEscape(value))
+			if err != nil {
+				return nil, err
+			}
+		case "killOnEarlyCloseBytes":
+			cfg.killOnEarlyCloseBytes, err = strconv.Atoi(url.QueryEscape(value))
+			if err != nil {
+				return nil, err
+			}
+		// kill pool account and server
+		case "killUser":
+			cfg.killUser = value
+		case "killPasswd":
+			cfg.killPasswd = value
+		case "killNet":
+			cfg.killNet = value
+		case "killAddr":
+			cfg.killAddr = value
+		case "killTLS":
+			cfg.killTLS = value
+		default:
+			continue
 		}
+
+		// The wrapped driver sends unknown params to the server as system variables.
+		delete(cfg.Params, name)
 	}
 
 	if cfg.killPoolSize == 0 {
@@ -123,5 +374,47 @@ func ParseDSN(dsn string) (*Config, error) {
 		cfg.killTimeout = defaultKillTimeout
 	}
 
+	if cfg.killGrace == 0 {
+		cfg.killGrace = defaultKillGrace
+	}
+
+	if cfg.killOnEarlyCloseRows <= 0 {
+		cfg.killOnEarlyCloseRows = defaultKillOnEarlyCloseRows
+	}
+
+	if cfg.killOnEarlyCloseBytes <= 0 {
+		cfg.killOnEarlyCloseBytes = defaultKillOnEarlyCloseBytes
+	}
+
 	return &cfg, nil
 }
+
+// killConfig returns the configuration of the kill pool connections:
+// the data connection settings with the kill overrides applied.
+// No database is selected, so the kill account needs no privileges on it.
+func (cfg *Config) killConfig() (*mysql.Config, error) {
+	var kc = cfg.Config.Clone()
+	kc.DBName = ""
+
+	if cfg.killUser != "" {
+		kc.User = cfg.killUser
+		kc.Passwd = cfg.killPasswd
+	} else if cfg.killPasswd != "" {
+		kc.Passwd = cfg.killPasswd
+	}
+
+	if cfg.killNet != "" {
+		kc.Net = cfg.killNet
+	}
+
+	if cfg.killAddr != "" {
+		kc.Addr = cfg.killAddr
+	}
+
+	if cfg.killTLS != "" {
+		kc.TLSConfig = cfg.killTLS
+	}
+
+	// Parse the result again to normalize the address and the TLS config.
+	return mysql.ParseDSN(kc.FormatDSN())
+}
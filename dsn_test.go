@@ -0,0 +1,114 @@
+package sql
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestParseDSNKillParams(t *testing.T) {
+	var cfg, err = ParseDSN("user:password@tcp(localhost:3306)/dbname?killMode=escalate&killGrace=250ms&cancelMode=true&sql_mode=ANSI")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.killMode != KillEscalate {
+		t.Errorf("killMode = %v, want %v", cfg.killMode, KillEscalate)
+	}
+	if cfg.killGrace != 250*time.Millisecond {
+		t.Errorf("killGrace = %v, want %v", cfg.killGrace, 250*time.Millisecond)
+	}
+	if !cfg.cancelMode {
+		t.Error("cancelMode = false, want true")
+	}
+	if _, ok := cfg.Params["killMode"]; ok {
+		t.Error("killMode must not be sent to the server")
+	}
+	if cfg.Params["sql_mode"] != "ANSI" {
+		t.Errorf("sql_mode = %q, want %q", cfg.Params["sql_mode"], "ANSI")
+	}
+
+	if _, err = ParseDSN("/dbname?killMode=nope"); err == nil {
+		t.Error("expected an error for an invalid kill mode")
+	}
+}
+
+func TestFormatDSNRoundTrip(t *testing.T) {
+	var cfg = NewConfig()
+	cfg.User = "user"
+	cfg.Net = "tcp"
+	cfg.Addr = "localhost:3306"
+	cfg.DBName = "dbname"
+	cfg.killMode = KillConnection
+	cfg.killPoolSize = 3
+	cfg.executionTimeHint = true
+	cfg.queryTimeout = time.Minute
+	cfg.selectTimeout = 30 * time.Second
+	cfg.killOnEarlyClose = true
+	cfg.killOnEarlyCloseRows = 1000
+
+	var parsed, err = ParseDSN(cfg.FormatDSN())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if parsed.killMode != cfg.killMode {
+		t.Errorf("killMode = %v, want %v", parsed.killMode, cfg.killMode)
+	}
+	if parsed.killPoolSize != cfg.killPoolSize {
+		t.Errorf("killPoolSize = %d, want %d", parsed.killPoolSize, cfg.killPoolSize)
+	}
+	if parsed.killGrace != cfg.killGrace {
+		t.Errorf("killGrace = %v, want %v", parsed.killGrace, cfg.killGrace)
+	}
+	if parsed.queryTimeout != cfg.queryTimeout {
+		t.Errorf("queryTimeout = %v, want %v", parsed.queryTimeout, cfg.queryTimeout)
+	}
+	if parsed.selectTimeout != cfg.selectTimeout {
+		t.Errorf("selectTimeout = %v, want %v", parsed.selectTimeout, cfg.selectTimeout)
+	}
+	if !parsed.killOnEarlyClose || parsed.killOnEarlyCloseRows != 1000 || parsed.killOnEarlyCloseBytes != defaultKillOnEarlyCloseBytes {
+		t.Errorf("killOnEarlyClose = %t, %d rows, %d bytes; want true, 1000 rows, %d bytes",
+			parsed.killOnEarlyClose, parsed.killOnEarlyCloseRows, parsed.killOnEarlyCloseBytes, defaultKillOnEarlyCloseBytes)
+	}
+	if !parsed.executionTimeHint {
+		t.Error("executionTimeHint = false, want true")
+	}
+}
+
+func TestKillConfig(t *testing.T) {
+	var cfg, err = ParseDSN("app:secret@tcp(proxy:6033)/orders?killUser=killer&killPasswd=p%26ss&killAddr=db1:33062&killTLS=skip-verify")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var kc *mysql.Config
+	if kc, err = cfg.killConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	if kc.User != "killer" || kc.Passwd != "p&ss" {
+		t.Errorf("kill credentials = %s:%s, want killer:p&ss", kc.User, kc.Passwd)
+	}
+	if kc.Addr != "db1:33062" {
+		t.Errorf("kill addr = %s, want db1:33062", kc.Addr)
+	}
+	if kc.TLSConfig != "skip-verify" {
+		t.Errorf("kill TLS = %s, want skip-verify", kc.TLSConfig)
+	}
+	if kc.DBName != "" {
+		t.Errorf("kill DBName = %s, want none", kc.DBName)
+	}
+	if cfg.User != "app" || cfg.Addr != "proxy:6033" || cfg.TLSConfig != "" {
+		t.Errorf("data connection settings changed: %s@%s tls=%q", cfg.User, cfg.Addr, cfg.TLSConfig)
+	}
+
+	var parsed *Config
+	if parsed, err = ParseDSN(cfg.FormatDSN()); err != nil {
+		t.Fatal(err)
+	}
+	if parsed.killPasswd != cfg.killPasswd || parsed.killAddr != cfg.killAddr {
+		t.Errorf("round trip = %s@%s, want %s@%s", parsed.killPasswd, parsed.killAddr, cfg.killPasswd, cfg.killAddr)
+	}
+}
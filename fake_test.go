@@ -0,0 +1,373 @@
+package sql
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// errInterrupted is what MySQL returns for a killed statement.
+var errInterrupted = &mysql.MySQLError{Number: 1317, Message: "Query execution was interrupted"}
+
+// fakeServer is an in-memory stand-in for a MySQL server.
+// Statements starting with "SLEEP" block until they are killed, and
+// queries of "STREAM" return rows until they are killed;
+// "KILL QUERY n" and "KILL CONNECTION n" interrupt them, except that
+// statements containing "STUCK" only give in to "KILL CONNECTION n".
+type fakeServer struct {
+	mu      sync.Mutex
+	nextID  int
+	running map[string]*fakeStatement
+	kills   []string
+
+	// version is returned by SELECT VERSION().
+	version string
+
+	// onExec, if set, is called by every statement before it returns.
+	onExec func(ctx context.Context, query string)
+
+	// pingErr, if set, is returned by every ping.
+	pingErr error
+
+	// killErr, if set, is returned by every kill statement.
+	killErr error
+
+	// skipArgs makes connections return driver.ErrSkip for statements
+	// with arguments, like the driver without interpolateParams.
+	skipArgs bool
+}
+
+type fakeStatement struct {
+	query  string
+	killed chan struct{}
+}
+
+func newFakeServer() *fakeServer {
+	return &fakeServer{running: make(map[string]*fakeStatement), version: "8.0.30"}
+}
+
+func (s *fakeServer) connector() driver.Connector {
+	return fakeConnector{s}
+}
+
+// start registers a sleeping statement of connection id and
+// returns the channel closed when it is killed.
+func (s *fakeServer) start(id string, query string) chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var stmt = &fakeStatement{query: query, killed: make(chan struct{})}
+	s.running[id] = stmt
+	return stmt.killed
+}
+
+func (s *fakeServer) finish(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.running, id)
+}
+
+func (s *fakeServer) kill(stmt string) {
+	var fields = strings.Fields(stmt)
+	var id = fields[len(fields)-1]
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.kills = append(s.kills, stmt)
+	if s.killErr != nil {
+		return
+	}
+	if running, ok := s.running[id]; ok {
+		if strings.Contains(running.query, "STUCK") && !strings.HasPrefix(stmt, "KILL CONNECTION ") {
+			return
+		}
+		close(running.killed)
+		delete(s.running, id)
+	}
+}
+
+func (s *fakeServer) killStatements() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.kills...)
+}
+
+// info returns the statement connection id is running, like the
+// INFO column of the process list.
+func (s *fakeServer) info(id string) driver.Value {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if stmt, ok := s.running[id]; ok {
+		return stmt.query
+	}
+	return nil
+}
+
+// sleeping reports whether connection id is running a sleeping statement.
+func (s *fakeServer) sleeping(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.running[id]
+	return ok
+}
+
+type fakeConnector struct {
+	server *fakeServer
+}
+
+func (c fakeConnector) Connect(context.Context) (driver.Conn, error) {
+	c.server.mu.Lock()
+	defer c.server.mu.Unlock()
+	c.server.nextID++
+	return &fakeConn{server: c.server, id: strconv.Itoa(c.server.nextID)}, nil
+}
+
+func (c fakeConnector) Driver() driver.Driver {
+	return nil
+}
+
+type fakeConn struct {
+	server *fakeServer
+	id     string
+	closed bool
+}
+
+func (c *fakeConn) exec(ctx context.Context, query string) error {
+	if c.server.onExec != nil {
+		defer c.server.onExec(ctx, query)
+	}
+
+	switch {
+	case strings.HasPrefix(query, "KILL "):
+		c.server.kill(query)
+		return c.server.killErr
+	case strings.Contains(query, "SLEEP"):
+		var killed = c.server.start(c.id, query)
+		defer c.server.finish(c.id)
+		select {
+		case <-killed:
+			return errInterrupted
+		case <-ctx.Done():
+			return driver.ErrBadConn
+		}
+	}
+	return nil
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if c.server.skipArgs && len(args) != 0 {
+		return nil, driver.ErrSkip
+	}
+	if err := c.exec(ctx, query); err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	switch {
+	case query == "SELECT CONNECTION_ID()":
+		return &fakeResultRows{columns: []string{"CONNECTION_ID()"}, values: [][]driver.Value{{[]uint8(c.id)}}}, nil
+	case query == "SELECT VERSION()":
+		return &fakeResultRows{columns: []string{"VERSION()"}, values: [][]driver.Value{{[]uint8(c.server.version)}}}, nil
+	case strings.HasPrefix(query, "SELECT COMMAND, INFO FROM information_schema.PROCESSLIST"):
+		var info = c.server.info(fmt.Sprint(args[0].Value))
+		if info == nil {
+			return &fakeResultRows{columns: []string{"COMMAND", "INFO"}, values: [][]driver.Value{{"Sleep", nil}}}, nil
+		}
+		return &fakeResultRows{columns: []string{"COMMAND", "INFO"}, values: [][]driver.Value{{"Query", info}}}, nil
+	case strings.HasPrefix(query, "SELECT QUERY_ID FROM information_schema.PROCESSLIST"):
+		return nil, errors.New("Unknown column 'QUERY_ID' in 'field list'")
+	case strings.Contains(query, "STREAM"):
+		return &fakeStreamRows{server: c.server, id: c.id, killed: c.server.start(c.id, query)}, nil
+	}
+	if err := c.exec(ctx, query); err != nil {
+		return nil, err
+	}
+	return &fakeResultRows{columns: []string{"1"}, values: [][]driver.Value{{int64(1)}}}, nil
+}
+
+func (c *fakeConn) Exec(query string, _ []driver.Value) (driver.Result, error) {
+	return c.ExecContext(context.Background(), query, nil)
+}
+
+func (c *fakeConn) Query(query string, _ []driver.Value) (driver.Rows, error) {
+	return c.QueryContext(context.Background(), query, nil)
+}
+
+func (c *fakeConn) PrepareContext(_ context.Context, query string) (driver.Stmt, error) {
+	return &fakeStmt{conn: c, query: query}, nil
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *fakeConn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) {
+	return &fakeTx{conn: c}, nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *fakeConn) Ping(context.Context) error {
+	return c.server.pingErr
+}
+
+func (c *fakeConn) ResetSession(context.Context) error {
+	return nil
+}
+
+func (c *fakeConn) IsValid() bool {
+	return !c.closed
+}
+
+func (c *fakeConn) CheckNamedValue(*driver.NamedValue) error {
+	return nil
+}
+
+func (c *fakeConn) Close() error {
+	c.closed = true
+	return nil
+}
+
+type fakeStmt struct {
+	conn  *fakeConn
+	query string
+}
+
+func (s *fakeStmt) Close() error {
+	return nil
+}
+
+func (s *fakeStmt) NumInput() int {
+	return -1
+}
+
+func (s *fakeStmt) Exec([]driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), nil)
+}
+
+func (s *fakeStmt) ExecContext(ctx context.Context, _ []driver.NamedValue) (driver.Result, error) {
+	if err := s.conn.exec(ctx, s.query); err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s *fakeStmt) Query([]driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), nil)
+}
+
+func (s *fakeStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.conn.QueryContext(ctx, s.query, args)
+}
+
+func (s *fakeStmt) CheckNamedValue(*driver.NamedValue) error {
+	return nil
+}
+
+type fakeTx struct {
+	conn *fakeConn
+}
+
+func (tx *fakeTx) Commit() error {
+	return tx.conn.exec(context.Background(), "SLEEP COMMIT")
+}
+
+func (tx *fakeTx) Rollback() error {
+	return tx.conn.exec(context.Background(), "SLEEP ROLLBACK")
+}
+
+type fakeResultRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *fakeResultRows) Columns() []string {
+	return r.columns
+}
+
+func (r *fakeResultRows) Close() error {
+	return nil
+}
+
+func (r *fakeResultRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+// fakeStreamRows are the endless rows of a "STREAM" query.
+type fakeStreamRows struct {
+	server *fakeServer
+	id     string
+	killed chan struct{}
+}
+
+func (r *fakeStreamRows) Columns() []string {
+	return []string{"n"}
+}
+
+func (r *fakeStreamRows) Close() error {
+	r.server.finish(r.id)
+	return nil
+}
+
+func (r *fakeStreamRows) Next(dest []driver.Value) error {
+	select {
+	case <-r.killed:
+		return errInterrupted
+	default:
+		dest[0] = int64(1)
+		return nil
+	}
+}
+
+// newTestConnector returns a cancellable Connector whose data connections
+// and kill pool are connected to server.
+func newTestConnector(t testing.TB, server *fakeServer, opts ...Option) *Connector {
+	t.Helper()
+
+	// The fake server takes arguments as the driver does with interpolateParams.
+	var cfg = NewConfig()
+	cfg.InterpolateParams = true
+	var c, err = NewConnector(cfg, append([]Option{WithCancelMode(true)}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.connector = server.connector()
+	c.killer.pool.release()
+	c.killer.pool = newKillPool(server.connector(), c.cfg.killPoolSize, c.cfg.killPoolPing)
+	t.Cleanup(func() {
+		c.Close()
+	})
+	return c
+}
+
+// connect returns a connection of c.
+func connect(t testing.TB, c *Connector) *cancellableMysqlConn {
+	t.Helper()
+
+	var conn, err = c.Connect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn.(*cancellableMysqlConn)
+}
+
+func isInterrupted(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == errInterrupted.Number
+}
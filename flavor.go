@@ -0,0 +1,226 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Flavor adapts the killing of queries to a server flavor: MySQL itself,
+// a fork such as MariaDB, or a server or proxy speaking its protocol.
+//
+// The flavor of a connection is detected from the server version string
+// when it is opened, unless one is set with the flavor DSN parameter or
+// WithFlavor. Additional flavors can be added with RegisterFlavor.
+type Flavor interface {
+	// Name returns the name of the flavor, as given to the flavor DSN parameter.
+	Name() string
+
+	// Detect reports whether a server with the VERSION() string version
+	// is of the flavor.
+	Detect(version string) bool
+
+	// ConnectionID returns the ID the kill pool kills the queries of conn by.
+	ConnectionID(ctx context.Context, conn driver.Conn) (ConnectionID, error)
+
+	// KillStatement returns the statement killing the query (KillQuery)
+	// or the connection (KillConnection) of connectionID. It may query
+	// pool, the kill pool, to build it. A statement returned along with
+	// an error is a fallback: the error is logged and the statement sent.
+	KillStatement(ctx context.Context, pool *sql.DB, connectionID ConnectionID, mode KillMode) (string, error)
+
+	// Process returns what the connection of connectionID is executing,
+	// as seen through pool, or nil if it is gone. It is used to confirm
+	// kills. Flavors that can not inspect connections return an error
+	// wrapping errors.ErrUnsupported.
+	Process(ctx context.Context, pool *sql.DB, connectionID ConnectionID) (*Process, error)
+}
+
+// Process describes what a connection is executing.
+type Process struct {
+	// Command is the type of command, e.g. "Query" or "Sleep".
+	Command string
+	// Info is the statement being executed, if any.
+	Info string
+}
+
+// The built-in flavors.
+var (
+	// FlavorMySQL is the flavor of MySQL and of servers not detected as another flavor.
+	FlavorMySQL Flavor = mysqlFlavor{}
+	// FlavorMariaDB kills queries by their query ID, looked up when the
+	// kill is sent. The next query of the connection can still be hit if
+	// it started before the lookup.
+	FlavorMariaDB Flavor = mariaDBFlavor{}
+	// FlavorTiDB kills with KILL TIDB, which targets the TiDB instance the
+	// kill pool is connected to.
+	FlavorTiDB Flavor = tidbFlavor{}
+	// FlavorVitess kills vtgate sessions.
+	FlavorVitess Flavor = vitessFlavor{}
+	// FlavorProxySQL kills ProxySQL sessions. ProxySQL usually reports the
+	// version of its backends, so it must be selected explicitly.
+	FlavorProxySQL Flavor = proxySQLFlavor{}
+)
+
+var (
+	flavorsMu sync.RWMutex
+	// flavors are detected in order; FlavorMySQL detects any server and comes last.
+	flavors = []Flavor{FlavorMariaDB, FlavorTiDB, FlavorVitess, FlavorProxySQL, FlavorMySQL}
+)
+
+// RegisterFlavor makes f available to the flavor DSN parameter and to
+// detection, ahead of the flavors registered before it. A flavor
+// registered with the name of another flavor replaces it.
+func RegisterFlavor(f Flavor) {
+	flavorsMu.Lock()
+	defer flavorsMu.Unlock()
+
+	var registered = []Flavor{f}
+	for _, other := range flavors {
+		if other.Name() != f.Name() {
+			registered = append(registered, other)
+		}
+	}
+	flavors = registered
+}
+
+// lookupFlavor returns the registered flavor called name.
+func lookupFlavor(name string) (Flavor, error) {
+	flavorsMu.RLock()
+	defer flavorsMu.RUnlock()
+
+	for _, f := range flavors {
+		if f.Name() == name {
+			return f, nil
+		}
+	}
+	return nil, fmt.Errorf("sql: unknown flavor %q", name)
+}
+
+// detectFlavor returns the first registered flavor detecting version.
+func detectFlavor(version string) Flavor {
+	flavorsMu.RLock()
+	defer flavorsMu.RUnlock()
+
+	for _, f := range flavors {
+		if f.Detect(version) {
+			return f
+		}
+	}
+	return FlavorMySQL
+}
+
+// isMariaDB reports whether f is the MariaDB flavor, which limits
+// statement execution time with a different syntax.
+func isMariaDB(f Flavor) bool {
+	var _, ok = f.(mariaDBFlavor)
+	return ok
+}
+
+type mysqlFlavor struct{}
+
+func (mysqlFlavor) Name() string { return "mysql" }
+
+func (mysqlFlavor) Detect(string) bool { return true }
+
+func (mysqlFlavor) ConnectionID(ctx context.Context, conn driver.Conn) (ConnectionID, error) {
+	var id, err = queryValue(ctx, conn, "SELECT CONNECTION_ID()")
+	if err != nil {
+		return 0, err
+	}
+	return parseConnectionID(id)
+}
+
+func (mysqlFlavor) KillStatement(_ context.Context, _ *sql.DB, connectionID ConnectionID, mode KillMode) (string, error) {
+	if mode == KillConnection {
+		return fmt.Sprintf("KILL CONNECTION %d", connectionID), nil
+	}
+	return fmt.Sprintf("KILL QUERY %d", connectionID), nil
+}
+
+func (mysqlFlavor) Process(ctx context.Context, pool *sql.DB, connectionID ConnectionID) (*Process, error) {
+	var command string
+	var info sql.NullString
+	var err = pool.QueryRowContext(ctx, "SELECT COMMAND, INFO FROM information_schema.PROCESSLIST WHERE ID = ?", connectionID).Scan(&command, &info)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Process{Command: command, Info: info.String}, nil
+}
+
+type mariaDBFlavor struct{ mysqlFlavor }
+
+func (mariaDBFlavor) Name() string { return "mariadb" }
+
+func (mariaDBFlavor) Detect(version string) bool {
+	return strings.Contains(strings.ToLower(version), "mariadb")
+}
+
+// KillStatement kills the query by the query ID the connection is
+// executing when the kill is sent, so that the kill statement can not
+// reach a query started after the lookup. If the query ID can not be
+// found the query is killed by connection, and the lookup error returned
+// along with the fallback statement.
+func (f mariaDBFlavor) KillStatement(ctx context.Context, pool *sql.DB, connectionID ConnectionID, mode KillMode) (string, error) {
+	if mode == KillConnection {
+		return f.mysqlFlavor.KillStatement(ctx, pool, connectionID, mode)
+	}
+
+	var queryID int64
+	var err = pool.QueryRowContext(ctx, "SELECT QUERY_ID FROM information_schema.PROCESSLIST WHERE ID = ?", connectionID).Scan(&queryID)
+	if err != nil {
+		var qry, _ = f.mysqlFlavor.KillStatement(ctx, pool, connectionID, mode)
+		return qry, fmt.Errorf("sql: query id of connection %d: %w", connectionID, err)
+	}
+	return fmt.Sprintf("KILL QUERY ID %d", queryID), nil
+}
+
+type tidbFlavor struct{ mysqlFlavor }
+
+func (tidbFlavor) Name() string { return "tidb" }
+
+func (tidbFlavor) Detect(version string) bool {
+	return strings.Contains(strings.ToLower(version), "tidb")
+}
+
+func (tidbFlavor) KillStatement(_ context.Context, _ *sql.DB, connectionID ConnectionID, mode KillMode) (string, error) {
+	if mode == KillConnection {
+		return fmt.Sprintf("KILL TIDB CONNECTION %d", connectionID), nil
+	}
+	return fmt.Sprintf("KILL TIDB QUERY %d", connectionID), nil
+}
+
+type vitessFlavor struct{ mysqlFlavor }
+
+func (vitessFlavor) Name() string { return "vitess" }
+
+func (vitessFlavor) Detect(version string) bool {
+	return strings.Contains(strings.ToLower(version), "vitess")
+}
+
+// Process is unsupported: the process list of vtgate is not queryable
+// and the one of a tablet does not show vtgate sessions.
+func (vitessFlavor) Process(context.Context, *sql.DB, ConnectionID) (*Process, error) {
+	return nil, fmt.Errorf("sql: vitess process list: %w", errors.ErrUnsupported)
+}
+
+type proxySQLFlavor struct{ mysqlFlavor }
+
+func (proxySQLFlavor) Name() string { return "proxysql" }
+
+func (proxySQLFlavor) Detect(version string) bool {
+	return strings.Contains(strings.ToLower(version), "proxysql")
+}
+
+// Process is unsupported: the sessions of ProxySQL are only listed on its
+// admin interface.
+func (proxySQLFlavor) Process(context.Context, *sql.DB, ConnectionID) (*Process, error) {
+	return nil, fmt.Errorf("sql: proxysql process list: %w", errors.ErrUnsupported)
+}
@@ -0,0 +1,82 @@
+package sql
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDetectFlavor(t *testing.T) {
+	var tests = []struct {
+		version string
+		want    Flavor
+	}{
+		{"8.0.30", FlavorMySQL},
+		{"10.6.12-MariaDB-1:10.6.12+maria~ubu2004", FlavorMariaDB},
+		{"5.7.25-TiDB-v7.1.0", FlavorTiDB},
+		{"8.0.30-Vitess", FlavorVitess},
+	}
+
+	for _, tt := range tests {
+		if got := detectFlavor(tt.version); got != tt.want {
+			t.Errorf("detectFlavor(%q) = %s, want %s", tt.version, got.Name(), tt.want.Name())
+		}
+	}
+}
+
+func TestParseDSNFlavor(t *testing.T) {
+	var cfg, err = ParseDSN("user@tcp(localhost:3306)/dbname?flavor=proxysql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.flavor != "proxysql" {
+		t.Errorf("flavor = %q, want proxysql", cfg.flavor)
+	}
+
+	if _, err = ParseDSN("user@tcp(localhost:3306)/dbname?flavor=oracle"); err == nil {
+		t.Error("unknown flavor accepted")
+	}
+}
+
+func TestExecContextCancelFlavor(t *testing.T) {
+	var server = newFakeServer()
+	server.version = "5.7.25-TiDB-v7.1.0"
+	var c = newTestConnector(t, server)
+	var conn = connect(t, c)
+	if conn.flavor != FlavorTiDB {
+		t.Fatalf("flavor = %s, want tidb", conn.flavor.Name())
+	}
+
+	var ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	var _, err = conn.ExecContext(ctx, "SLEEP", nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want %v", err, context.Canceled)
+	}
+	if kills := server.killStatements(); len(kills) != 1 || kills[0] != "KILL TIDB QUERY "+conn.connectionID.String() {
+		t.Errorf("kills = %v, want [KILL TIDB QUERY %s]", kills, conn.connectionID)
+	}
+}
+
+func TestExecContextCancelMariaDBFallback(t *testing.T) {
+	var server = newFakeServer()
+	server.version = "10.6.12-MariaDB"
+	var logger = &entryRecorder{event: EventKillFallback}
+	var conn = connect(t, newTestConnector(t, server, WithLogger(logger)))
+
+	var ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	if _, err := conn.ExecContext(ctx, "SLEEP", nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want %v", err, context.Canceled)
+	}
+	var want = "KILL QUERY " + conn.connectionID.String()
+	if kills := server.killStatements(); len(kills) != 1 || kills[0] != want {
+		t.Errorf("kills = %v, want [%s]", kills, want)
+	}
+	if entries := logger.recorded(); len(entries) != 1 || entries[0].Statement != want || entries[0].Err == nil {
+		t.Errorf("fallbacks = %+v, want one to %s with the lookup error", entries, want)
+	}
+}
@@ -0,0 +1,162 @@
+package sql
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"sync"
+	"syscall"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// handshakeNet and handshakeUnixNet are the networks the data connections
+// dial TCP and unix sockets with, so that the thread ID and server version
+// are read from the initial handshake packet of the server instead of
+// being queried, and the socket can be closed if a kill fails.
+const (
+	handshakeNet     = "mysqlc+tcp"
+	handshakeUnixNet = "mysqlc+unix"
+)
+
+var registerHandshakeNet sync.Once
+
+type handshakeKey struct{}
+
+// handshake holds what was read from the initial handshake packet.
+type handshake struct {
+	connectionID ConnectionID
+	version      string
+	ok           bool
+
+	// netConn is the socket of the connection, closed as the last resort
+	// of a kill.
+	netConn net.Conn
+}
+
+// withHandshake returns a context whose connection dialed on handshakeNet
+// records its handshake in the returned handshake.
+func withHandshake(ctx context.Context) (context.Context, *handshake) {
+	var h = &handshake{}
+	return context.WithValue(ctx, handshakeKey{}, h), h
+}
+
+// dataConfig returns the configuration of the data connections: cfg
+// dialing TCP on handshakeNet and unix sockets on handshakeUnixNet.
+// Other networks are dialed as configured, and their connection IDs
+// are queried.
+func dataConfig(cfg *mysql.Config) *mysql.Config {
+	var dc = cfg.Clone()
+
+	// Custom networks get no defaults from the upstream driver.
+	switch dc.Net {
+	case "", "tcp":
+		if dc.Addr == "" {
+			dc.Addr = "127.0.0.1:3306"
+		} else if _, _, err := net.SplitHostPort(dc.Addr); err != nil {
+			dc.Addr = net.JoinHostPort(dc.Addr, "3306")
+		}
+		dc.Net = handshakeNet
+	case "unix":
+		if dc.Addr == "" {
+			dc.Addr = "/tmp/mysql.sock"
+		}
+		dc.Net = handshakeUnixNet
+	default:
+		return dc
+	}
+
+	registerHandshakeNet.Do(func() {
+		mysql.RegisterDialContext(handshakeNet, func(ctx context.Context, addr string) (net.Conn, error) {
+			return dialHandshake(ctx, "tcp", addr)
+		})
+		mysql.RegisterDialContext(handshakeUnixNet, func(ctx context.Context, addr string) (net.Conn, error) {
+			return dialHandshake(ctx, "unix", addr)
+		})
+	})
+	return dc
+}
+
+// dialHandshake dials network like the upstream driver does and, if ctx
+// is from withHandshake, records the handshake of the connection.
+func dialHandshake(ctx context.Context, network, addr string) (net.Conn, error) {
+	var d net.Dialer
+	var conn, err = d.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	// The upstream driver only enables keepalives on a *net.TCPConn.
+	if tc, ok := conn.(*net.TCPConn); ok {
+		if err = tc.SetKeepAlive(true); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	var h, _ = ctx.Value(handshakeKey{}).(*handshake)
+	if h == nil {
+		return conn, nil
+	}
+	h.netConn = conn
+	return &sniffConn{Conn: conn, h: h}, nil
+}
+
+// sniffConn records the handshake packet read from the connection.
+type sniffConn struct {
+	net.Conn
+	h    *handshake
+	buf  []byte
+	done bool
+}
+
+func (c *sniffConn) Read(p []byte) (int, error) {
+	var n, err = c.Conn.Read(p)
+	if !c.done && n > 0 {
+		c.sniff(p[:n])
+	}
+	return n, err
+}
+
+func (c *sniffConn) sniff(b []byte) {
+	c.buf = append(c.buf, b...)
+	if len(c.buf) < 4 {
+		return
+	}
+
+	var length = int(c.buf[0]) | int(c.buf[1])<<8 | int(c.buf[2])<<16
+	if len(c.buf) < 4+length {
+		return
+	}
+	c.h.parse(c.buf[4 : 4+length])
+	c.done = true
+	c.buf = nil
+}
+
+// SyscallConn lets the upstream driver check the liveness of the socket.
+func (c *sniffConn) SyscallConn() (syscall.RawConn, error) {
+	var sc, ok = c.Conn.(syscall.Conn)
+	if !ok {
+		return nil, errors.ErrUnsupported
+	}
+	return sc.SyscallConn()
+}
+
+// parse parses the payload of a HandshakeV10 packet: the protocol version,
+// the NUL-terminated server version and the 4-byte thread ID.
+// Any other packet, such as an error, is ignored.
+func (h *handshake) parse(p []byte) {
+	if len(p) == 0 || p[0] != 10 {
+		return
+	}
+
+	var end = bytes.IndexByte(p[1:], 0)
+	if end < 0 || len(p) < 1+end+1+4 {
+		return
+	}
+	h.version = string(p[1 : 1+end])
+	h.connectionID = ConnectionID(binary.LittleEndian.Uint32(p[1+end+1:]))
+	h.ok = true
+}
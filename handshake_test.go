@@ -0,0 +1,143 @@
+package sql
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// handshakePacket returns a HandshakeV10 packet of a server with
+// version and thread ID id.
+func handshakePacket(version string, id uint32) []byte {
+	var payload = append([]byte{10}, version...)
+	payload = append(payload, 0)
+	payload = binary.LittleEndian.AppendUint32(payload, id)
+	payload = append(payload, "12345678"...)            // auth-plugin-data-part-1
+	payload = append(payload, 0, 0xff, 0xf7)            // filler, capability flags
+	payload = append(payload, 33, 2, 0, 0xff, 0x81, 21) // charset, status, capability flags, auth data length
+	payload = append(payload, make([]byte, 10)...)      // reserved
+	payload = append(payload, "123456789012\x00mysql_native_password\x00"...)
+
+	var packet = []byte{byte(len(payload)), byte(len(payload) >> 8), byte(len(payload) >> 16), 0}
+	return append(packet, payload...)
+}
+
+func TestSniffHandshake(t *testing.T) {
+	var client, server = net.Pipe()
+	defer client.Close()
+
+	var packet = handshakePacket("10.6.12-MariaDB", 42)
+	go func() {
+		// Split the packet to read it in pieces.
+		server.Write(packet[:3])
+		server.Write(packet[3:10])
+		server.Write(packet[10:])
+		server.Close()
+	}()
+
+	var h = &handshake{}
+	var conn = &sniffConn{Conn: client, h: h}
+	var buf = make([]byte, 4)
+	for {
+		if _, err := conn.Read(buf); err != nil {
+			break
+		}
+	}
+
+	if !h.ok || h.connectionID != 42 || h.version != "10.6.12-MariaDB" {
+		t.Errorf("handshake = %+v, want thread 42 of 10.6.12-MariaDB", *h)
+	}
+}
+
+func TestDialHandshake(t *testing.T) {
+	var ln, err = net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	go func() {
+		var conn, err = ln.Accept()
+		if err != nil {
+			return
+		}
+		conn.Write(handshakePacket("8.0.30", 7))
+		conn.Close()
+	}()
+
+	var cfg = mysql.NewConfig()
+	cfg.Net = "tcp"
+	cfg.Addr = ln.Addr().String()
+
+	var dc = dataConfig(cfg)
+	if dc.Net != handshakeNet {
+		t.Fatalf("Net = %q, want %q", dc.Net, handshakeNet)
+	}
+
+	var connector, cerr = mysql.NewConnector(dc)
+	if cerr != nil {
+		t.Fatal(cerr)
+	}
+
+	// The fake server hangs up after its handshake.
+	var ctx, h = withHandshake(context.Background())
+	if conn, err := connector.Connect(ctx); err == nil {
+		conn.Close()
+	}
+	if !h.ok || h.connectionID != 7 || h.version != "8.0.30" {
+		t.Errorf("handshake = %+v, want thread 7 of 8.0.30", *h)
+	}
+}
+
+func TestDialHandshakeUnix(t *testing.T) {
+	var ln, err = net.Listen("unix", filepath.Join(t.TempDir(), "mysql.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	go func() {
+		var conn, err = ln.Accept()
+		if err != nil {
+			return
+		}
+		conn.Write(handshakePacket("8.0.30", 9))
+		conn.Close()
+	}()
+
+	var cfg = mysql.NewConfig()
+	cfg.Net = "unix"
+	cfg.Addr = ln.Addr().String()
+
+	var dc = dataConfig(cfg)
+	if dc.Net != handshakeUnixNet {
+		t.Fatalf("Net = %q, want %q", dc.Net, handshakeUnixNet)
+	}
+
+	var connector, cerr = mysql.NewConnector(dc)
+	if cerr != nil {
+		t.Fatal(cerr)
+	}
+
+	var ctx, h = withHandshake(context.Background())
+	if conn, err := connector.Connect(ctx); err == nil {
+		conn.Close()
+	}
+	if !h.ok || h.connectionID != 9 || h.netConn == nil {
+		t.Errorf("handshake = %+v, want thread 9 and its socket", *h)
+	}
+}
+
+func TestDataConfigOtherNetworks(t *testing.T) {
+	var cfg = mysql.NewConfig()
+	cfg.Net = "custom"
+	cfg.Addr = "server:3306"
+
+	if dc := dataConfig(cfg); dc.Net != "custom" {
+		t.Errorf("Net = %q, want custom", dc.Net)
+	}
+}
@@ -4,19 +4,83 @@ package sql
 
 import (
 	"context"
-	"database/sql"
 	"database/sql/driver"
+	"errors"
 	"fmt"
-	"github.com/go-sql-driver/mysql"
-	"io/ioutil"
-	"log"
+	"net"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// KillMode selects the statement used to cancel a running query.
+type KillMode int
+
+const (
+	// KillQuery terminates the statement but leaves the connection open.
+	KillQuery KillMode = iota
+	// KillConnection terminates the statement and the connection executing it.
+	KillConnection
+	// KillEscalate sends KILL QUERY and, if the thread is still executing
+	// after the grace period, KILL CONNECTION.
+	KillEscalate
 )
 
-func determineConnectionId(ctx context.Context, conn driver.Conn) (string, error) {
+func (m KillMode) String() string {
+	switch m {
+	case KillQuery:
+		return "query"
+	case KillConnection:
+		return "connection"
+	case KillEscalate:
+		return "escalate"
+	}
+	return fmt.Sprintf("KillMode(%d)", int(m))
+}
+
+func parseKillMode(s string) (KillMode, error) {
+	switch s {
+	case "query":
+		return KillQuery, nil
+	case "connection":
+		return KillConnection, nil
+	case "escalate":
+		return KillEscalate, nil
+	}
+	return 0, fmt.Errorf("sql: invalid kill mode %q", s)
+}
+
+// ConnectionID is the ID of a connection on the server, the thread ID on
+// MySQL. The connections of a connector created with the cancel mode
+// disabled have the zero ID.
+type ConnectionID uint64
+
+func (id ConnectionID) String() string {
+	return strconv.FormatUint(uint64(id), 10)
+}
+
+func parseConnectionID(s string) (ConnectionID, error) {
+	var id, err = strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("sql: invalid connection id %q", s)
+	}
+	return ConnectionID(id), nil
+}
+
+// determineServerVersion returns the version string of the server.
+func determineServerVersion(ctx context.Context, conn driver.Conn) (string, error) {
+	return queryValue(ctx, conn, "SELECT VERSION()")
+}
+
+// queryValue returns the single value returned by query.
+func queryValue(ctx context.Context, conn driver.Conn, query string) (string, error) {
 	var querierCtx = conn.(driver.QueryerContext)
 
-	var rows, err = querierCtx.QueryContext(ctx, "SELECT CONNECTION_ID()", []driver.NamedValue{})
+	var rows, err = querierCtx.QueryContext(ctx, query, []driver.NamedValue{})
 	if err != nil {
 		return "", err
 	}
@@ -29,50 +93,282 @@ func determineConnectionId(ctx context.Context, conn driver.Conn) (string, error
 	}
 
 	if len(queryResult) != 1 {
-		return "", fmt.Errorf("sql: expected only one connection id in query results, not %d", len(queryResult))
+		return "", fmt.Errorf("sql: expected only one value in query results, not %d", len(queryResult))
 	}
 
 	var value = queryResult[0]
-	var connectionID = string(value.([]uint8))
-	if DebugMode {
-		log.Printf("Connection with ID=%s was determined!", connectionID)
-	}
-	return connectionID, nil
+	return string(value.([]uint8)), nil
 }
 
-// kill is used to kill a running query.
-// It is advised that db be another pool that the
-// connection was NOT derived from.
-func kill(db *sql.DB, connectionID string, kto time.Duration) error {
-	if !CancelModeUsage {
-		return nil
+// killer kills the queries of the connections created by one connector.
+// It is advised that pool be another pool that the
+// connections were NOT derived from.
+type killer struct {
+	pool    *killPool
+	timeout time.Duration
+	mode    KillMode
+	grace   time.Duration
+	logger  Logger
+	metrics MetricsSink
+	name    string
+	tracer  trace.Tracer
+	verify  bool
+	history *killHistory
+}
+
+// ErrQueryFinished is returned by a kill which was skipped because the
+// query it was meant for had already finished.
+var ErrQueryFinished = errors.New("sql: query already finished")
+
+// kill is used to kill the running query described by info.
+// ctx is the context of the query; it is only used for logging,
+// metrics and tracing.
+// The kill statements are built by flavor, the flavor of the connection.
+// A nil killer kills nothing, and neither does a killer
+// given the empty connection ID of a connection created
+// with the cancel mode disabled.
+//
+// When kills are verified and the connection is no longer executing
+// the statement marked by info, the kill is skipped and ErrQueryFinished
+// is returned, so that a finished query can not take down the next
+// statement of its connection.
+// It returns the path the last kill statement was sent through.
+func (k *killer) kill(ctx context.Context, flavor Flavor, info *QueryInfo) (KillPath, error) {
+	if k == nil {
+		return "", nil
 	}
-	if connectionID == "" {
-		return nil
+
+	var labels = MetricLabels{Connector: k.name, Statement: classify(info.Query)}
+	if info.ConnectionID == 0 {
+		return "", nil
 	}
 
-	var qry = fmt.Sprintf("KILL QUERY %s", connectionID)
+	var span trace.Span
+	ctx, span = startSpan(ctx, k.tracer, "mysqlc.kill", info.ConnectionID, info.Query)
+	span.SetAttributes(
+		attribute.String("mysqlc.kill_mode", k.mode.String()),
+		attribute.String("mysqlc.flavor", flavor.Name()),
+	)
 
-	if kto == 0 {
-		_, err := db.Exec(qry)
-		if DebugMode {
-			fmt.Printf("Connection %s killed\n", connectionID)
+	if k.verify && info.marker != "" {
+		var running, err = k.statementRunning(flavor, info.ConnectionID, info.marker)
+		if err == nil && !running {
+			k.metrics.KillSkipped(labels)
+			k.record(ctx, LogEntry{
+				Event:        EventKillSkipped,
+				ConnectionID: info.ConnectionID,
+				Query:        fingerprint(info.Query),
+				Cause:        contextCause(ctx),
+			})
+			span.AddEvent("mysqlc.kill_skipped")
+			endSpan(span, nil)
+			return "", ErrQueryFinished
 		}
+		// A failed verification must not prevent the kill.
+	}
+
+	var path, err = k.sendKill(ctx, flavor, info.ConnectionID, info.Query, labels)
+	endSpan(span, err)
+	return path, err
+}
+
+// cancelled counts the query described by info as canceled by its context.
+func (k *killer) cancelled(info *QueryInfo) {
+	if k == nil {
+		return
+	}
+	k.metrics.QueryCancelled(MetricLabels{Connector: k.name, Statement: classify(info.Query)})
+}
+
+// sendKill sends the kill statements of the kill mode, built by flavor.
+func (k *killer) sendKill(ctx context.Context, flavor Flavor, connectionID ConnectionID, query string, labels MetricLabels) (KillPath, error) {
+	var fp = fingerprint(query)
+	switch k.mode {
+	case KillConnection:
+		return k.execKill(ctx, flavor, KillConnection, connectionID, fp, labels)
+	case KillEscalate:
+		var path, err = k.execKill(ctx, flavor, KillQuery, connectionID, fp, labels)
 		if err != nil {
-			return err
+			return path, err
+		}
+
+		var running bool
+		if running, err = k.awaitIdle(flavor, connectionID); err != nil {
+			return path, err
+		}
+		if !running {
+			return path, nil
+		}
+		return k.execKill(ctx, flavor, KillConnection, connectionID, fp, labels)
+	default:
+		return k.execKill(ctx, flavor, KillQuery, connectionID, fp, labels)
+	}
+}
+
+// execKill builds the kill statement of mode with flavor and executes it.
+func (k *killer) execKill(ctx context.Context, flavor Flavor, mode KillMode, connectionID ConnectionID, fp string, labels MetricLabels) (KillPath, error) {
+	var killCtx, cancelFunc = k.context()
+	defer cancelFunc()
+
+	var qry, err = flavor.KillStatement(killCtx, k.pool.DB, connectionID, mode)
+	if err != nil && qry != "" {
+		// The flavor fell back to another statement.
+		k.record(ctx, LogEntry{
+			Event:        EventKillFallback,
+			ConnectionID: connectionID,
+			Query:        fp,
+			Statement:    qry,
+			Cause:        contextCause(ctx),
+			Err:          err,
+		})
+		err = nil
+	}
+	if err != nil {
+		k.metrics.KillFailed(labels)
+		k.record(ctx, LogEntry{
+			Event:        EventKillFailed,
+			ConnectionID: connectionID,
+			Query:        fp,
+			Cause:        contextCause(ctx),
+			Err:          err,
+		})
+		return "", err
+	}
+	return k.exec(ctx, qry, connectionID, fp, labels)
+}
+
+// context returns the context of a round trip of the kill pool.
+func (k *killer) context() (context.Context, context.CancelFunc) {
+	return k.contextFrom(context.Background())
+}
+
+// contextFrom returns parent bounded by the kill timeout.
+func (k *killer) contextFrom(parent context.Context) (context.Context, context.CancelFunc) {
+	if k.timeout == 0 {
+		return parent, func() {}
+	}
+	return context.WithTimeout(parent, k.timeout)
+}
+
+func (k *killer) exec(ctx context.Context, qry string, connectionID ConnectionID, fp string, labels MetricLabels) (path KillPath, err error) {
+	// The kill round-trip is traced as a child of the kill span.
+	var span trace.Span
+	ctx, span = k.tracer.Start(ctx, "mysqlc.kill.exec",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "mysql"),
+			attribute.String("db.statement", qry),
+		),
+	)
+	defer func() { endSpan(span, err) }()
+
+	var killCtx, cancelFunc = k.context()
+	defer cancelFunc()
+
+	var start = time.Now()
+	path = KillPathPool
+	_, err = k.pool.ExecContext(killCtx, qry)
+	if err != nil && unreachable(err) {
+		// The kill pool may be unable to connect to an overloaded server.
+		if rerr := k.pool.reserve.exec(qry, k.timeout); rerr != nil {
+			err = errors.Join(err, rerr)
+		} else {
+			path, err = KillPathReserved, nil
 		}
+	}
+	span.SetAttributes(attribute.String("mysqlc.kill_path", string(path)))
+
+	var entry = LogEntry{
+		Event:        EventKillSent,
+		ConnectionID: connectionID,
+		Query:        fp,
+		Statement:    qry,
+		Latency:      time.Since(start),
+		Cause:        contextCause(ctx),
+		Path:         path,
+	}
+	if err != nil {
+		entry.Event = EventKillFailed
+		entry.Err = err
+		k.metrics.KillFailed(labels)
 	} else {
-		ctx, cancelFunc := context.WithTimeout(context.Background(), kto)
-		defer cancelFunc()
-		_, err := db.ExecContext(ctx, qry)
-		if err == nil && DebugMode {
-			_ = mysql.SetLogger(log.New(ioutil.Discard, "", 0))
-			log.Printf("Connection %s has been closed! \n", connectionID)
+		k.metrics.KillIssued(labels, entry.Latency)
+	}
+	k.record(ctx, entry)
+
+	return path, err
+}
+
+// Error numbers of the server refusing a connection.
+const (
+	errConCount               = 1040 // ER_CON_COUNT_ERROR
+	errTooManyUserConnections = 1203 // ER_TOO_MANY_USER_CONNECTIONS
+)
+
+// unreachable reports whether err tells that the kill pool could not
+// reach the server: it could not connect, lost its connection, or timed
+// out. Any other error of the server, e.g. an unknown thread, answers
+// the kill itself and is not retried.
+func unreachable(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == errConCount || mysqlErr.Number == errTooManyUserConnections
+	}
+	var netErr net.Error
+	return errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, mysql.ErrInvalidConn) ||
+		errors.Is(err, context.DeadlineExceeded) ||
+		errors.As(err, &netErr)
+}
+
+// awaitIdle watches the thread of connectionID for the grace period.
+// It reports whether the thread is still executing a statement once
+// the grace period has passed. If flavor can not inspect the thread,
+// it is assumed to be executing.
+func (k *killer) awaitIdle(flavor Flavor, connectionID ConnectionID) (bool, error) {
+	var interval = k.grace / 10
+	if interval < minKillPollInterval {
+		interval = minKillPollInterval
+	}
+
+	var deadline = time.Now().Add(k.grace)
+	for {
+		var running, err = k.threadRunning(flavor, connectionID)
+		if errors.Is(err, errors.ErrUnsupported) {
+			time.Sleep(k.grace)
+			return true, nil
 		}
-		if err != nil {
-			return err
+		if err != nil || !running {
+			return running, err
+		}
+		if !time.Now().Before(deadline) {
+			return true, nil
 		}
+		time.Sleep(interval)
 	}
+}
 
-	return nil
+// statementRunning reports whether the thread of connectionID is
+// executing the statement marked by marker.
+func (k *killer) statementRunning(flavor Flavor, connectionID ConnectionID, marker string) (bool, error) {
+	var ctx, cancelFunc = k.context()
+	defer cancelFunc()
+
+	var p, err = flavor.Process(ctx, k.pool.DB, connectionID)
+	if err != nil || p == nil {
+		return false, err
+	}
+	return strings.Contains(p.Info, marker), nil
+}
+
+// threadRunning reports whether the thread of connectionID is executing a statement.
+func (k *killer) threadRunning(flavor Flavor, connectionID ConnectionID) (bool, error) {
+	var ctx, cancelFunc = k.context()
+	defer cancelFunc()
+
+	var p, err = flavor.Process(ctx, k.pool.DB, connectionID)
+	if err != nil || p == nil {
+		return false, err
+	}
+	return p.Command != "Sleep", nil
 }
@@ -0,0 +1,110 @@
+package sql
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// hasTimeLimit reports whether query already limits its execution time.
+func hasTimeLimit(query string) bool {
+	var upper = strings.ToUpper(query)
+	return strings.Contains(upper, "MAX_EXECUTION_TIME") || strings.Contains(upper, "MAX_STATEMENT_TIME")
+}
+
+// hintQuery returns the SELECT query limited to run for d on the server:
+// with a MAX_EXECUTION_TIME optimizer hint on MySQL, and wrapped in
+// SET STATEMENT max_statement_time on MariaDB.
+// Other statements, and queries which already have a limit, are returned
+// unchanged.
+func hintQuery(query string, d time.Duration, mariaDB bool) string {
+	if d <= 0 || classify(query) != StatementSelect || hasTimeLimit(query) {
+		return query
+	}
+
+	if mariaDB {
+		return fmt.Sprintf("SET STATEMENT max_statement_time=%.3f FOR %s", d.Seconds(), query)
+	}
+
+	// The hint must follow the SELECT keyword of the query block.
+	var start, end = firstKeywordSpan(query)
+	if !strings.EqualFold(query[start:end], "SELECT") {
+		return query
+	}
+	return fmt.Sprintf("%s /*+ MAX_EXECUTION_TIME(%d) */%s", query[:end], executionTimeMillis(d), query[end:])
+}
+
+// executionTimeMillis returns d in milliseconds, rounded up.
+func executionTimeMillis(d time.Duration) int64 {
+	return int64((d + time.Millisecond - 1) / time.Millisecond)
+}
+
+// timeLeft returns the time left until the deadline of ctx,
+// or 0 if there is no deadline or it has passed.
+func timeLeft(ctx context.Context) time.Duration {
+	var deadline, ok = ctx.Deadline()
+	if !ok {
+		return 0
+	}
+	if d := time.Until(deadline); d > 0 {
+		return d
+	}
+	return 0
+}
+
+// hint returns query limited to the time left until the deadline of ctx,
+// if the connector is configured to do so.
+func (c *cancellableMysqlConn) hint(ctx context.Context, query string) string {
+	if !c.connector.cfg.executionTimeHint {
+		return query
+	}
+	return hintQuery(query, timeLeft(ctx), isMariaDB(c.flavor))
+}
+
+// executionTimeVariable returns the session variable limiting the
+// execution time of SELECT statements on the server of the connection.
+func (c *cancellableMysqlConn) executionTimeVariable() string {
+	if isMariaDB(c.flavor) {
+		return "max_statement_time"
+	}
+	return "max_execution_time"
+}
+
+// limitExecutionTime limits the SELECT statements of the session to the
+// time left until the deadline of ctx. The text of a prepared statement can
+// not be hinted per execution, so its executions are limited with the
+// session variable instead. A variable set by the DSN is left alone, as the
+// reset would overwrite it. It reports whether the limit was set and must be
+// reset with resetExecutionTime.
+func (c *cancellableMysqlConn) limitExecutionTime(ctx context.Context, query string) bool {
+	if !c.connector.cfg.executionTimeHint || classify(query) != StatementSelect || hasTimeLimit(query) {
+		return false
+	}
+	var name = c.executionTimeVariable()
+	if _, ok := c.connector.cfg.Params[name]; ok {
+		return false
+	}
+
+	var d = timeLeft(ctx)
+	if d <= 0 {
+		return false
+	}
+
+	var set = fmt.Sprintf("SET SESSION %s = %d", name, executionTimeMillis(d))
+	if isMariaDB(c.flavor) {
+		set = fmt.Sprintf("SET SESSION %s = %.3f", name, d.Seconds())
+	}
+	var _, err = c.conn.(driver.ExecerContext).ExecContext(context.Background(), set, nil)
+	return err == nil
+}
+
+// resetExecutionTime resets the limit set by limitExecutionTime.
+func (c *cancellableMysqlConn) resetExecutionTime() {
+	var reset = fmt.Sprintf("SET SESSION %s = DEFAULT", c.executionTimeVariable())
+	if _, err := c.conn.(driver.ExecerContext).ExecContext(context.Background(), reset, nil); err != nil {
+		// A session with an unknown limit must not be reused.
+		c.markBad()
+	}
+}
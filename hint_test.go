@@ -0,0 +1,113 @@
+package sql
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHintQuery(t *testing.T) {
+	var tests = []struct {
+		query   string
+		d       time.Duration
+		mariaDB bool
+		want    string
+	}{
+		{"SELECT * FROM t", 1500 * time.Millisecond, false, "SELECT /*+ MAX_EXECUTION_TIME(1500) */ * FROM t"},
+		{"  select id FROM t", 1500 * time.Microsecond, false, "  select /*+ MAX_EXECUTION_TIME(2) */ id FROM t"},
+		{"SELECT * FROM t", 1500 * time.Millisecond, true, "SET STATEMENT max_statement_time=1.500 FOR SELECT * FROM t"},
+		{"SELECT /*+ MAX_EXECUTION_TIME(10) */ * FROM t", time.Second, false, "SELECT /*+ MAX_EXECUTION_TIME(10) */ * FROM t"},
+		{"WITH c AS (SELECT 1) SELECT * FROM c", time.Second, false, "WITH c AS (SELECT 1) SELECT * FROM c"},
+		{"UPDATE t SET a = 1", time.Second, false, "UPDATE t SET a = 1"},
+		{"SELECT * FROM t", 0, false, "SELECT * FROM t"},
+	}
+
+	for _, tt := range tests {
+		if got := hintQuery(tt.query, tt.d, tt.mariaDB); got != tt.want {
+			t.Errorf("hintQuery(%q, %v, %v) = %q, want %q", tt.query, tt.d, tt.mariaDB, got, tt.want)
+		}
+	}
+}
+
+func TestQueryContextExecutionTimeHint(t *testing.T) {
+	var server = newFakeServer()
+	var mu sync.Mutex
+	var queries []string
+	server.onExec = func(_ context.Context, query string) {
+		mu.Lock()
+		defer mu.Unlock()
+		queries = append(queries, query)
+	}
+	var c = newTestConnector(t, server, WithExecutionTimeHint(true))
+	var conn = connect(t, c)
+
+	var ctx, cancel = context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	var rows, err = conn.QueryContext(ctx, "SELECT 1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows.Close()
+
+	var stmt driver.Stmt
+	stmt, err = conn.PrepareContext(ctx, "SELECT 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err = stmt.(driver.StmtQueryContext).QueryContext(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(queries) != 4 {
+		t.Fatalf("queries = %q, want 4", queries)
+	}
+	if !strings.HasPrefix(queries[0], "SELECT /*+ MAX_EXECUTION_TIME(") {
+		t.Errorf("query = %q, want a MAX_EXECUTION_TIME hint", queries[0])
+	}
+	if !strings.HasPrefix(queries[1], "SET SESSION max_execution_time = ") ||
+		queries[2] != "SELECT 2" ||
+		queries[3] != "SET SESSION max_execution_time = DEFAULT" {
+		t.Errorf("statement queries = %q, want the session limit set and reset", queries[1:])
+	}
+}
+
+func TestStmtExecutionTimeSetByDSN(t *testing.T) {
+	var server = newFakeServer()
+	var mu sync.Mutex
+	var queries []string
+	server.onExec = func(_ context.Context, query string) {
+		mu.Lock()
+		defer mu.Unlock()
+		queries = append(queries, query)
+	}
+	var c = newTestConnector(t, server, WithExecutionTimeHint(true))
+	c.cfg.Params = map[string]string{"max_execution_time": "1000"}
+	var conn = connect(t, c)
+
+	var ctx, cancel = context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	var stmt, err = conn.PrepareContext(ctx, "SELECT 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := stmt.(driver.StmtQueryContext).QueryContext(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(queries) != 1 || queries[0] != "SELECT 2" {
+		t.Errorf("queries = %q, want the statement alone", queries)
+	}
+}
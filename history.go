@@ -0,0 +1,57 @@
+package sql
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// killHistorySize is the number of kills a connector remembers.
+const killHistorySize = 100
+
+// killRecord is a kill remembered by the kill history.
+type killRecord struct {
+	Time time.Time
+	LogEntry
+}
+
+// killHistory remembers the most recent kills of a connector.
+type killHistory struct {
+	mu      sync.Mutex
+	records []killRecord
+	next    int
+}
+
+func (h *killHistory) add(entry LogEntry) {
+	if h == nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var r = killRecord{Time: time.Now(), LogEntry: entry}
+	if len(h.records) < killHistorySize {
+		h.records = append(h.records, r)
+		return
+	}
+	h.records[h.next] = r
+	h.next = (h.next + 1) % killHistorySize
+}
+
+// snapshot returns the remembered kills, most recent first.
+func (h *killHistory) snapshot() []killRecord {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var records = make([]killRecord, 0, len(h.records))
+	for i := len(h.records) - 1; i >= 0; i-- {
+		records = append(records, h.records[(h.next+i)%len(h.records)])
+	}
+	return records
+}
+
+// record logs the kill event entry and adds it to the kill history.
+func (k *killer) record(ctx context.Context, entry LogEntry) {
+	k.history.add(entry)
+	k.logger.Log(ctx, entry)
+}
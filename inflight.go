@@ -0,0 +1,235 @@
+package sql
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// QueryID identifies an in-flight query of a connector.
+type QueryID uint64
+
+// InFlightQuery describes a query being executed, as returned by
+// Connector.InFlight.
+type InFlightQuery struct {
+	ID           QueryID
+	ConnectionID ConnectionID
+	// Query is the fingerprint of the SQL.
+	Query string
+	// Args are the arguments of the query, as returned by the Redactor.
+	Args     []interface{}
+	Start    time.Time
+	Deadline time.Time // zero if the query has no deadline
+	Labels   map[string]string
+}
+
+// Redactor returns the arguments of a query as shown by Connector.InFlight.
+type Redactor func(query string, args []driver.NamedValue) []interface{}
+
+// RedactAll is a Redactor which replaces every argument with "?".
+func RedactAll(_ string, args []driver.NamedValue) []interface{} {
+	var redacted = make([]interface{}, len(args))
+	for i := range redacted {
+		redacted[i] = "?"
+	}
+	return redacted
+}
+
+// WithRedactor sets the Redactor of the arguments shown by Connector.InFlight.
+// By default the arguments are shown as they are.
+func WithRedactor(r Redactor) Option {
+	return func(c *Connector) {
+		c.redactor = r
+	}
+}
+
+type queryLabelsKey struct{}
+
+// WithQueryLabels returns a context whose queries are shown by
+// Connector.InFlight with labels, in addition to those of ctx.
+func WithQueryLabels(ctx context.Context, labels map[string]string) context.Context {
+	var merged = make(map[string]string, len(labels))
+	for k, v := range queryLabels(ctx) {
+		merged[k] = v
+	}
+	for k, v := range labels {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, queryLabelsKey{}, merged)
+}
+
+func queryLabels(ctx context.Context) map[string]string {
+	var labels, _ = ctx.Value(queryLabelsKey{}).(map[string]string)
+	return labels
+}
+
+// ErrQueryNotFound is returned by Connector.Cancel for a query which
+// is not in flight.
+var ErrQueryNotFound = errors.New("sql: query not in flight")
+
+// ErrQueryNotSent is returned by Connector.Cancel for an in-flight query
+// whose statement is not sent yet.
+var ErrQueryNotSent = errors.New("sql: query not sent yet")
+
+// inFlightQuery is an entry of the registry.
+type inFlightQuery struct {
+	conn     *cancellableMysqlConn
+	info     *QueryInfo
+	start    time.Time
+	deadline time.Time
+	labels   map[string]string
+	timedOut bool       // set once the watchdog killed the query
+	watch    *killWatch // set once the statement is sent
+}
+
+// registry keeps the in-flight queries of a connector. Queries enter it
+// when they are sent and leave it when they complete or, for queries
+// returning rows, when their rows are closed.
+type registry struct {
+	mu      sync.Mutex
+	next    QueryID
+	queries map[QueryID]*inFlightQuery
+
+	// watchdog, if set, is started by the first query entering
+	// while it is not running.
+	watchdog func()
+	watching bool
+
+	// drained is set by Connector.Shutdown, which no query enters after,
+	// and closed when the last query leaves.
+	drained chan struct{}
+}
+
+// enter adds a query to the registry. It returns ErrShuttingDown once
+// the connector is shutting down.
+func (r *registry) enter(ctx context.Context, conn *cancellableMysqlConn, info *QueryInfo) error {
+	var q = &inFlightQuery{conn: conn, info: info, start: time.Now(), labels: queryLabels(ctx)}
+	q.deadline, _ = ctx.Deadline()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.drained != nil {
+		return ErrShuttingDown
+	}
+	if r.queries == nil {
+		r.queries = make(map[QueryID]*inFlightQuery)
+	}
+	r.next++
+	info.id = r.next
+	r.queries[info.id] = q
+
+	if r.watchdog != nil && !r.watching {
+		r.watching = true
+		go r.watchdog()
+	}
+	return nil
+}
+
+func (r *registry) leave(info *QueryInfo) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.queries[info.id]; !ok {
+		return
+	}
+	delete(r.queries, info.id)
+	if r.drained != nil && len(r.queries) == 0 {
+		close(r.drained)
+	}
+}
+
+// sent records the watch of a query whose statement is about to be sent.
+// Once the connector is shutting down, the statements of the queries
+// which entered before are refused with ErrShuttingDown instead.
+func (r *registry) sent(info *QueryInfo, w *killWatch) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if q, ok := r.queries[info.id]; ok && q.info == info {
+		if r.drained != nil {
+			return ErrShuttingDown
+		}
+		q.watch = w
+	}
+	return nil
+}
+
+// watch returns the watch of q, or nil if its statement is not sent yet.
+func (r *registry) watch(q *inFlightQuery) *killWatch {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return q.watch
+}
+
+func (r *registry) get(id QueryID) *inFlightQuery {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.queries[id]
+}
+
+// InFlight returns the queries of the connector being executed,
+// oldest first.
+func (c *Connector) InFlight() []InFlightQuery {
+	c.queries.mu.Lock()
+	var queries = make([]*inFlightQuery, 0, len(c.queries.queries))
+	for _, q := range c.queries.queries {
+		queries = append(queries, q)
+	}
+	c.queries.mu.Unlock()
+
+	var snapshot = make([]InFlightQuery, len(queries))
+	for i, q := range queries {
+		var args []interface{}
+		if c.redactor != nil {
+			args = c.redactor(q.info.Query, q.info.Args)
+		} else {
+			args = make([]interface{}, len(q.info.Args))
+			for j, arg := range q.info.Args {
+				args[j] = arg.Value
+			}
+		}
+
+		snapshot[i] = InFlightQuery{
+			ID:           q.info.id,
+			ConnectionID: q.info.ConnectionID,
+			Query:        fingerprint(q.info.Query),
+			Args:         args,
+			Start:        q.start,
+			Deadline:     q.deadline,
+			Labels:       q.labels,
+		}
+	}
+	sort.Slice(snapshot, func(i, j int) bool {
+		return snapshot[i].ID < snapshot[j].ID
+	})
+	return snapshot
+}
+
+// Cancel kills the in-flight query id. The query returns the error of
+// the killed statement, as its context is not canceled.
+// It returns ErrQueryNotFound if the query is no longer in flight,
+// ErrQueryNotSent if its statement is not sent yet, and ErrQueryFinished
+// if the statement returned before the kill: the kill goes through the
+// watch of the query, so it can not hit the next statement of the
+// connection.
+func (c *Connector) Cancel(id QueryID) error {
+	var q = c.queries.get(id)
+	if q == nil {
+		return ErrQueryNotFound
+	}
+	if q.info.ConnectionID == 0 {
+		return fmt.Errorf("sql: query %d can not be killed without the cancel mode", id)
+	}
+	var w = c.queries.watch(q)
+	if w == nil {
+		return ErrQueryNotSent
+	}
+	if !w.kill(context.Background(), q.conn, q.info) {
+		return ErrQueryFinished
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.killErr
+}
@@ -0,0 +1,114 @@
+package sql
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+	"time"
+)
+
+// awaitInFlight waits for c to have n in-flight queries and returns them.
+func awaitInFlight(t *testing.T, c *Connector, n int) []InFlightQuery {
+	t.Helper()
+
+	var deadline = time.Now().Add(time.Second)
+	for {
+		var queries = c.InFlight()
+		if len(queries) == n {
+			return queries
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("in-flight queries = %+v, want %d", queries, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestInFlightCancel(t *testing.T) {
+	var server = newFakeServer()
+	var c = newTestConnector(t, server, WithRedactor(RedactAll))
+	var conn = connect(t, c)
+
+	var ctx = WithQueryLabels(context.Background(), map[string]string{"handler": "report"})
+	var errs = make(chan error, 1)
+	go func() {
+		var _, err = conn.ExecContext(ctx, "SLEEP WHERE id = ?", []driver.NamedValue{{Ordinal: 1, Value: int64(42)}})
+		errs <- err
+	}()
+
+	var q = awaitInFlight(t, c, 1)[0]
+	if q.ConnectionID != conn.connectionID || q.Query != "SLEEP WHERE id = ?" || q.Labels["handler"] != "report" {
+		t.Errorf("in-flight query = %+v", q)
+	}
+	if len(q.Args) != 1 || q.Args[0] != "?" {
+		t.Errorf("args = %v, want redacted", q.Args)
+	}
+	if !q.Deadline.IsZero() {
+		t.Errorf("deadline = %v, want none", q.Deadline)
+	}
+
+	// The statement is sent once it sleeps on the server.
+	for !server.sleeping(conn.connectionID.String()) {
+		time.Sleep(time.Millisecond)
+	}
+	if err := c.Cancel(q.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errs; !isInterrupted(err) {
+		t.Errorf("err = %v, want the interrupted statement", err)
+	}
+	if conn.IsValid() {
+		t.Error("killed connection is still valid")
+	}
+	awaitInFlight(t, c, 0)
+
+	if err := c.Cancel(q.ID); err != ErrQueryNotFound {
+		t.Errorf("Cancel of a finished query = %v, want %v", err, ErrQueryNotFound)
+	}
+}
+
+func TestInFlightCancelFinished(t *testing.T) {
+	var server = newFakeServer()
+	var c = newTestConnector(t, server)
+	var conn = connect(t, c)
+
+	var info = &QueryInfo{Query: "DO 1", ConnectionID: conn.connectionID}
+	if err := c.queries.enter(context.Background(), conn, info); err != nil {
+		t.Fatal(err)
+	}
+	defer c.queries.leave(info)
+	if err := c.Cancel(info.id); err != ErrQueryNotSent {
+		t.Errorf("Cancel of an unsent query = %v, want %v", err, ErrQueryNotSent)
+	}
+
+	// The statement returned, but the query has not left the registry.
+	var w, err = conn.watch(context.Background(), info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.done()
+	if err := c.Cancel(info.id); err != ErrQueryFinished {
+		t.Errorf("Cancel of a returned query = %v, want %v", err, ErrQueryFinished)
+	}
+	if kills := server.killStatements(); len(kills) != 0 {
+		t.Errorf("kills = %v, want none", kills)
+	}
+	if !conn.IsValid() {
+		t.Error("connection invalidated by a skipped kill")
+	}
+}
+
+func TestInFlightRows(t *testing.T) {
+	var server = newFakeServer()
+	var c = newTestConnector(t, server)
+	var conn = connect(t, c)
+
+	var rows, err = conn.QueryContext(context.Background(), "SELECT 1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	awaitInFlight(t, c, 1)
+
+	rows.Close()
+	awaitInFlight(t, c, 0)
+}
@@ -0,0 +1,125 @@
+package sql
+
+import (
+	"context"
+	"database/sql/driver"
+)
+
+// QueryInfo describes the statement an Interceptor hook is called for.
+type QueryInfo struct {
+	Query        string
+	Args         []driver.NamedValue
+	ConnectionID ConnectionID
+
+	// marker is the comment identifying the statement on the server.
+	marker string
+	// id identifies the statement in the registry of in-flight queries.
+	id QueryID
+}
+
+// Interceptor hooks into the statements executed through a Connector.
+//
+// BeforeQuery, BeforeExec and OnPrepare may rewrite info.Query and info.Args,
+// and may reject the statement by returning an error. Rewriting the query of a
+// prepared statement is only possible in OnPrepare; its executions can only
+// rewrite the arguments.
+// OnKill is called after a kill attempt with its result.
+// Implementations must be safe for concurrent use.
+type Interceptor interface {
+	BeforeQuery(ctx context.Context, info *QueryInfo) error
+	AfterQuery(ctx context.Context, info *QueryInfo, err error)
+	BeforeExec(ctx context.Context, info *QueryInfo) error
+	AfterExec(ctx context.Context, info *QueryInfo, err error)
+	OnPrepare(ctx context.Context, info *QueryInfo) error
+	OnBeginTx(ctx context.Context, info *QueryInfo, opts driver.TxOptions) error
+	OnKill(ctx context.Context, info *QueryInfo, err error)
+}
+
+// NopInterceptor implements every Interceptor hook as a no-op.
+// Embed it to implement only some of the hooks.
+type NopInterceptor struct{}
+
+func (NopInterceptor) BeforeQuery(context.Context, *QueryInfo) error                 { return nil }
+func (NopInterceptor) AfterQuery(context.Context, *QueryInfo, error)                 {}
+func (NopInterceptor) BeforeExec(context.Context, *QueryInfo) error                  { return nil }
+func (NopInterceptor) AfterExec(context.Context, *QueryInfo, error)                  {}
+func (NopInterceptor) OnPrepare(context.Context, *QueryInfo) error                   { return nil }
+func (NopInterceptor) OnBeginTx(context.Context, *QueryInfo, driver.TxOptions) error { return nil }
+func (NopInterceptor) OnKill(context.Context, *QueryInfo, error)                     {}
+
+// WithInterceptors appends interceptors to the chain of the connector.
+// Before hooks run in the order the interceptors were added and stop at the
+// first error; after hooks run in reverse order, and only if all before
+// hooks succeeded.
+func WithInterceptors(interceptors ...Interceptor) Option {
+	return func(c *Connector) {
+		c.interceptors = append(c.interceptors, interceptors...)
+	}
+}
+
+// interceptorChain runs the hooks of several interceptors.
+type interceptorChain []Interceptor
+
+func (ic interceptorChain) beforeQuery(ctx context.Context, info *QueryInfo) error {
+	for _, i := range ic {
+		if err := i.BeforeQuery(ctx, info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// afterQuery runs the AfterQuery hooks, unless the statement was skipped
+// for database/sql to prepare it: the prepared statement reports it.
+func (ic interceptorChain) afterQuery(ctx context.Context, info *QueryInfo, err error) {
+	if err == driver.ErrSkip {
+		return
+	}
+	for n := len(ic) - 1; n >= 0; n-- {
+		ic[n].AfterQuery(ctx, info, err)
+	}
+}
+
+func (ic interceptorChain) beforeExec(ctx context.Context, info *QueryInfo) error {
+	for _, i := range ic {
+		if err := i.BeforeExec(ctx, info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// afterExec runs the AfterExec hooks, unless the statement was skipped
+// like in afterQuery.
+func (ic interceptorChain) afterExec(ctx context.Context, info *QueryInfo, err error) {
+	if err == driver.ErrSkip {
+		return
+	}
+	for n := len(ic) - 1; n >= 0; n-- {
+		ic[n].AfterExec(ctx, info, err)
+	}
+}
+
+func (ic interceptorChain) onPrepare(ctx context.Context, info *QueryInfo) error {
+	for _, i := range ic {
+		if err := i.OnPrepare(ctx, info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ic interceptorChain) onBeginTx(ctx context.Context, info *QueryInfo, opts driver.TxOptions) error {
+	for _, i := range ic {
+		if err := i.OnBeginTx(ctx, info, opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ic interceptorChain) onKill(ctx context.Context, info *QueryInfo, err error) {
+	for _, i := range ic {
+		i.OnKill(ctx, info, err)
+	}
+}
@@ -0,0 +1,286 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordingInterceptor struct {
+	NopInterceptor
+	name   string
+	calls  *[]string
+	reject error
+}
+
+func (r recordingInterceptor) BeforeQuery(_ context.Context, info *QueryInfo) error {
+	*r.calls = append(*r.calls, "before "+r.name)
+	info.Query += " /* " + r.name + " */"
+	return r.reject
+}
+
+func (r recordingInterceptor) AfterQuery(context.Context, *QueryInfo, error) {
+	*r.calls = append(*r.calls, "after "+r.name)
+}
+
+func TestInterceptorChain(t *testing.T) {
+	var calls []string
+	var chain = interceptorChain{
+		recordingInterceptor{name: "a", calls: &calls},
+		recordingInterceptor{name: "b", calls: &calls},
+	}
+
+	var info = &QueryInfo{Query: "SELECT 1"}
+	if err := chain.beforeQuery(context.Background(), info); err != nil {
+		t.Fatal(err)
+	}
+	chain.afterQuery(context.Background(), info, nil)
+
+	if want := "SELECT 1 /* a */ /* b */"; info.Query != want {
+		t.Errorf("query = %q, want %q", info.Query, want)
+	}
+	if want := []string{"before a", "before b", "after b", "after a"}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+}
+
+func TestInterceptorChainReject(t *testing.T) {
+	var calls []string
+	var denied = errors.New("denied")
+	var chain = interceptorChain{
+		recordingInterceptor{name: "a", calls: &calls, reject: denied},
+		recordingInterceptor{name: "b", calls: &calls},
+	}
+
+	if err := chain.beforeQuery(context.Background(), &QueryInfo{}); err != denied {
+		t.Errorf("err = %v, want %v", err, denied)
+	}
+	if want := []string{"before a"}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+}
+
+// hookRecorder records the hooks called through a connector.
+type hookRecorder struct {
+	mu    sync.Mutex
+	hooks []string
+}
+
+func (r *hookRecorder) add(hook string, info *QueryInfo) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.hooks = append(r.hooks, hook+" "+info.Query)
+}
+
+func (r *hookRecorder) recorded() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.hooks...)
+}
+
+func (r *hookRecorder) BeforeQuery(_ context.Context, info *QueryInfo) error {
+	r.add("BeforeQuery", info)
+	return nil
+}
+
+func (r *hookRecorder) AfterQuery(_ context.Context, info *QueryInfo, _ error) {
+	r.add("AfterQuery", info)
+}
+
+func (r *hookRecorder) BeforeExec(_ context.Context, info *QueryInfo) error {
+	r.add("BeforeExec", info)
+	return nil
+}
+
+func (r *hookRecorder) AfterExec(_ context.Context, info *QueryInfo, _ error) {
+	r.add("AfterExec", info)
+}
+
+func (r *hookRecorder) OnPrepare(_ context.Context, info *QueryInfo) error {
+	r.add("OnPrepare", info)
+	return nil
+}
+
+func (r *hookRecorder) OnBeginTx(_ context.Context, info *QueryInfo, _ driver.TxOptions) error {
+	r.add("OnBeginTx", info)
+	return nil
+}
+
+func (r *hookRecorder) OnKill(_ context.Context, info *QueryInfo, _ error) {
+	r.add("OnKill", info)
+}
+
+func TestInterceptorHooks(t *testing.T) {
+	var server = newFakeServer()
+	var rec = &hookRecorder{}
+	var conn = connect(t, newTestConnector(t, server, WithInterceptors(rec)))
+	var ctx = context.Background()
+
+	if _, err := conn.ExecContext(ctx, "DO 1", nil); err != nil {
+		t.Fatal(err)
+	}
+	var rows, err = conn.QueryContext(ctx, "SELECT 1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows.Close()
+
+	var stmt driver.Stmt
+	if stmt, err = conn.PrepareContext(ctx, "SELECT 2"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = stmt.(driver.StmtExecContext).ExecContext(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	if rows, err = stmt.(driver.StmtQueryContext).QueryContext(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	rows.Close()
+
+	if _, err = conn.BeginTx(ctx, driver.TxOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	var timeoutCtx, cancel = context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if _, err = conn.ExecContext(timeoutCtx, "SLEEP", nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	var want = []string{
+		"BeforeExec DO 1", "AfterExec DO 1",
+		"BeforeQuery SELECT 1", "AfterQuery SELECT 1",
+		"OnPrepare SELECT 2",
+		"BeforeExec SELECT 2", "AfterExec SELECT 2",
+		"BeforeQuery SELECT 2", "AfterQuery SELECT 2",
+		"OnBeginTx ",
+		"BeforeExec SLEEP", "OnKill SLEEP", "AfterExec SLEEP",
+	}
+	if hooks := rec.recorded(); !reflect.DeepEqual(hooks, want) {
+		t.Errorf("hooks = %q, want %q", hooks, want)
+	}
+}
+
+// rewriter adds a comment to the statements it sees.
+type rewriter struct {
+	NopInterceptor
+}
+
+func (rewriter) BeforeExec(_ context.Context, info *QueryInfo) error {
+	info.Query += " /* rewritten */"
+	return nil
+}
+
+func (rewriter) OnPrepare(_ context.Context, info *QueryInfo) error {
+	info.Query += " /* rewritten */"
+	return nil
+}
+
+func TestInterceptorRewritesQuery(t *testing.T) {
+	var server = newFakeServer()
+	var mu sync.Mutex
+	var queries []string
+	server.onExec = func(_ context.Context, query string) {
+		mu.Lock()
+		defer mu.Unlock()
+		queries = append(queries, query)
+	}
+	var conn = connect(t, newTestConnector(t, server, WithInterceptors(rewriter{})))
+
+	if _, err := conn.ExecContext(context.Background(), "DO 1", nil); err != nil {
+		t.Fatal(err)
+	}
+	var stmt, err = conn.PrepareContext(context.Background(), "DO 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = stmt.(driver.StmtExecContext).ExecContext(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if want := []string{"DO 1 /* rewritten */", "DO 2 /* rewritten */"}; !reflect.DeepEqual(queries, want) {
+		t.Errorf("queries = %q, want %q", queries, want)
+	}
+}
+
+// execRecorder counts the BeforeExec hooks and records the errors
+// given to the AfterExec hooks.
+type execRecorder struct {
+	NopInterceptor
+	mu     sync.Mutex
+	before int
+	after  []error
+}
+
+func (r *execRecorder) BeforeExec(context.Context, *QueryInfo) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.before++
+	return nil
+}
+
+func (r *execRecorder) AfterExec(_ context.Context, _ *QueryInfo, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.after = append(r.after, err)
+}
+
+func TestInterceptorSkippedExec(t *testing.T) {
+	for _, interpolate := range []bool{false, true} {
+		var server = newFakeServer()
+		server.skipArgs = true
+		var rec = &execRecorder{}
+		var c = newTestConnector(t, server, WithInterceptors(rec))
+		// With interpolateParams the driver skips the statements it can not interpolate.
+		c.cfg.InterpolateParams = interpolate
+
+		var db = sql.OpenDB(c)
+		if _, err := db.ExecContext(context.Background(), "DO ?", 1); err != nil {
+			t.Fatal(err)
+		}
+		db.Close()
+
+		rec.mu.Lock()
+		if !interpolate && rec.before != 1 {
+			t.Errorf("BeforeExec ran %d times, want once", rec.before)
+		}
+		if len(rec.after) != 1 || rec.after[0] != nil {
+			t.Errorf("AfterExec errors = %v, want the prepared statement's alone", rec.after)
+		}
+		rec.mu.Unlock()
+	}
+}
+
+func TestInterceptorNoKillWithoutCancelMode(t *testing.T) {
+	var server = newFakeServer()
+	var rec = &hookRecorder{}
+	var conn = connect(t, newTestConnector(t, server, WithCancelMode(false), WithInterceptors(rec)))
+	if conn.connectionID != 0 {
+		t.Fatalf("connection ID = %v, want none without the cancel mode", conn.connectionID)
+	}
+
+	var ctx, cancel = context.WithCancel(context.Background())
+	var rows, err = conn.QueryContext(ctx, "SELECT 1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	rows.Close()
+
+	if kills := server.killStatements(); len(kills) != 0 {
+		t.Errorf("kills = %v, want none", kills)
+	}
+	for _, hook := range rec.recorded() {
+		if strings.HasPrefix(hook, "OnKill") {
+			t.Errorf("hooks = %q, want no OnKill", rec.recorded())
+		}
+	}
+}
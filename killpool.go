@@ -0,0 +1,215 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// defaultKillPoolPing is the default interval at which the kill pool
+// connections are pinged.
+const defaultKillPoolPing = 30 * time.Second
+
+// errKillPoolClosed is returned by a kill sent after the connector was closed.
+var errKillPoolClosed = errors.New("sql: kill pool closed")
+
+// killPool holds the kill connections to a server. The connectors with
+// the same kill pool settings, i.e. the same server address, credentials,
+// size and ping interval, share one kill pool, which is closed when the
+// last of them is closed.
+type killPool struct {
+	*sql.DB
+	reserve *reservedConn
+
+	key  string // empty for a pool not in killPools
+	refs int    // guarded by killPools.mu
+
+	size          int
+	pingInterval  time.Duration
+	keepaliveOnce sync.Once
+	ready         int32 // accessed atomically
+	stop          chan struct{}
+
+	// pinging holds a token while keepalive holds the connections, so
+	// that concurrent calls do not each hold a part of the pool.
+	pinging chan struct{}
+}
+
+// killPools are the kill pools in use in the process, by key.
+var killPools = struct {
+	mu    sync.Mutex
+	pools map[string]*killPool
+}{pools: make(map[string]*killPool)}
+
+// newKillPool returns a kill pool of size connections of connector.
+// The pool is not shared.
+func newKillPool(connector driver.Connector, size int, pingInterval time.Duration) *killPool {
+	var db = sql.OpenDB(connector)
+	db.SetMaxOpenConns(size)
+	db.SetMaxIdleConns(size)
+	return &killPool{
+		DB:           db,
+		reserve:      &reservedConn{connector: connector},
+		refs:         1,
+		size:         size,
+		pingInterval: pingInterval,
+		stop:         make(chan struct{}),
+		pinging:      make(chan struct{}, 1),
+	}
+}
+
+// acquireKillPool returns the kill pool of cfg, opening it unless it is
+// in use. Every call must be matched by a call to release.
+func acquireKillPool(cfg *mysql.Config, size int, pingInterval time.Duration) (*killPool, error) {
+	var key = fmt.Sprintf("%s size=%d ping=%s", cfg.FormatDSN(), size, pingInterval)
+
+	killPools.mu.Lock()
+	defer killPools.mu.Unlock()
+	if p, ok := killPools.pools[key]; ok {
+		p.refs++
+		return p, nil
+	}
+
+	var connector, err = mysql.NewConnector(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var p = newKillPool(connector, size, pingInterval)
+	p.key = key
+	killPools.pools[key] = p
+	return p, nil
+}
+
+// release drops a reference to the pool, closing it with the last one.
+func (p *killPool) release() error {
+	killPools.mu.Lock()
+	p.refs--
+	if p.refs > 0 {
+		killPools.mu.Unlock()
+		return nil
+	}
+	if p.key != "" {
+		delete(killPools.pools, p.key)
+	}
+	killPools.mu.Unlock()
+
+	close(p.stop)
+	p.reserve.close()
+	return p.DB.Close()
+}
+
+// Close closes the connector: the kill pool is closed unless other
+// connectors share it. It implements io.Closer, so that sql.DB.Close
+// closes the connector. Queries can not be killed once it is closed.
+func (c *Connector) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		if c.unobserve != nil {
+			c.unobserve()
+		}
+		err = c.killer.pool.release()
+	})
+	return err
+}
+
+// startKeepalive opens the connections of the kill pool and keeps them
+// alive in the background, once, until the pool is closed. It is started
+// by the first data connection, so that the kill pool holds its
+// connections before the server runs out of them.
+func (k *killer) startKeepalive() {
+	var p = k.pool
+	p.keepaliveOnce.Do(func() {
+		go func() {
+			k.keepalive(context.Background())
+			if p.pingInterval <= 0 {
+				return
+			}
+			var ticker = time.NewTicker(p.pingInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					k.keepalive(context.Background())
+				case <-p.stop:
+					return
+				}
+			}
+		}()
+	})
+}
+
+// keepalive takes every connection of the kill pool at once, pings them
+// and returns them to the pool as idle connections. Connections which
+// fail the ping, e.g. after a server restart, are replaced.
+// It returns the number of connections which are ready.
+// Calls are serialized; ctx bounds the wait for the others and the pings.
+// A call which can not wait returns the number ready at the last ping.
+func (k *killer) keepalive(ctx context.Context) int {
+	var killCtx, cancelFunc = k.contextFrom(ctx)
+	defer cancelFunc()
+
+	select {
+	case k.pool.pinging <- struct{}{}:
+		defer func() { <-k.pool.pinging }()
+	case <-killCtx.Done():
+		return int(atomic.LoadInt32(&k.pool.ready))
+	}
+
+	var conns = make([]*sql.Conn, 0, k.pool.size)
+	for i := 0; i < k.pool.size; i++ {
+		var conn, err = k.pingConn(killCtx)
+		if err != nil {
+			break
+		}
+		conns = append(conns, conn)
+	}
+	for _, conn := range conns {
+		conn.Close()
+	}
+	k.pool.reserve.ping(killCtx)
+
+	atomic.StoreInt32(&k.pool.ready, int32(len(conns)))
+	return len(conns)
+}
+
+// pingConn returns a pinged connection of the kill pool. A connection
+// which fails the ping is discarded and replaced once.
+func (k *killer) pingConn(ctx context.Context) (*sql.Conn, error) {
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		var conn *sql.Conn
+		if conn, err = k.pool.Conn(ctx); err != nil {
+			return nil, err
+		}
+		if err = conn.PingContext(ctx); err == nil {
+			return conn, nil
+		}
+		// database/sql discards connections which report driver.ErrBadConn.
+		conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+		conn.Close()
+	}
+	return nil, err
+}
+
+// KillPoolReady reports whether every connection of the kill pool was
+// open and answered the last ping.
+func (c *Connector) KillPoolReady() bool {
+	return int(atomic.LoadInt32(&c.killer.pool.ready)) >= c.killer.pool.size
+}
+
+// CheckKillPool pings the connections of the kill pool, reopening those
+// which fail, and returns an error unless all of them are ready.
+// It suits health checks.
+func (c *Connector) CheckKillPool(ctx context.Context) error {
+	if ready := c.killer.keepalive(ctx); ready < c.killer.pool.size {
+		return fmt.Errorf("sql: %d of %d kill pool connections ready", ready, c.killer.pool.size)
+	}
+	return nil
+}
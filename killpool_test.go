@@ -0,0 +1,123 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestKillPoolKeepalive(t *testing.T) {
+	var c = newTestConnector(t, newFakeServer(), WithKillPoolSize(2))
+	connect(t, c)
+
+	// The first data connection opens the kill pool in the background.
+	var deadline = time.Now().Add(time.Second)
+	for !c.KillPoolReady() {
+		if time.Now().After(deadline) {
+			t.Fatal("kill pool not ready")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if idle := c.killer.pool.Stats().Idle; idle != 2 {
+		t.Errorf("idle kill pool connections = %d, want 2", idle)
+	}
+
+	if err := c.CheckKillPool(context.Background()); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestKillPoolNotReady(t *testing.T) {
+	var c = newTestConnector(t, newFakeServer())
+	c.killer.pool.Close()
+	c.killer.pool.DB = sql.OpenDB(failingConnector{})
+
+	if err := c.CheckKillPool(context.Background()); err == nil {
+		t.Error("CheckKillPool of an unreachable server succeeded")
+	}
+	if c.KillPoolReady() {
+		t.Error("kill pool of an unreachable server is ready")
+	}
+}
+
+func TestKillPoolShared(t *testing.T) {
+	var cfg = NewConfig()
+	cfg.Addr = "shared.test:3306"
+
+	var newConnector = func(opts ...Option) *Connector {
+		var c, err = NewConnector(cfg, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	var a, b = newConnector(), newConnector()
+	var other = newConnector(WithKillPoolSize(2))
+	defer other.Close()
+
+	if a.killer.pool != b.killer.pool {
+		t.Error("connectors of the same server do not share the kill pool")
+	}
+	if a.killer.pool == other.killer.pool {
+		t.Error("connectors with different kill pool sizes share the kill pool")
+	}
+
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if a.killer.pool.refs != 1 {
+		t.Errorf("kill pool references = %d, want 1", a.killer.pool.refs)
+	}
+
+	// sql.DB.Close closes the connector.
+	if err := sql.OpenDB(b).Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.killer.pool.PingContext(context.Background()); err == nil {
+		t.Error("kill pool still open after its last connector was closed")
+	}
+	if c := newConnector(); c.killer.pool == b.killer.pool {
+		t.Error("closed kill pool reused")
+	} else {
+		c.Close()
+	}
+}
+
+func TestKillPoolConcurrentChecks(t *testing.T) {
+	var c = newTestConnector(t, newFakeServer(), WithKillPoolSize(2), WithKillTimeout(time.Second))
+
+	var errs = make(chan error, 4)
+	for i := 0; i < cap(errs); i++ {
+		go func() {
+			errs <- c.CheckKillPool(context.Background())
+		}()
+	}
+	for i := 0; i < cap(errs); i++ {
+		if err := <-errs; err != nil {
+			t.Error(err)
+		}
+	}
+	if !c.KillPoolReady() {
+		t.Error("kill pool not ready after concurrent checks")
+	}
+}
+
+func TestKillPoolCheckHonorsContext(t *testing.T) {
+	var c = newTestConnector(t, newFakeServer())
+	c.killer.pool.pinging <- struct{}{}
+	defer func() { <-c.killer.pool.pinging }()
+
+	var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	var start = time.Now()
+	if err := c.CheckKillPool(ctx); err == nil {
+		t.Error("CheckKillPool of a busy pool succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("CheckKillPool took %v, want it bounded by its context", elapsed)
+	}
+}
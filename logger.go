@@ -0,0 +1,128 @@
+package sql
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Event identifies what a LogEntry reports.
+type Event string
+
+const (
+	EventConnectionOpened     Event = "connection opened"
+	EventConnectionIDResolved Event = "connection id resolved"
+	EventKillSent             Event = "kill sent"
+	EventKillFailed           Event = "kill failed"
+	EventKillSkipped          Event = "kill skipped, query already finished"
+	EventQueryTimedOut        Event = "query timed out"
+	EventConnectionClosed     Event = "connection closed, kill failed"
+	EventKillFallback         Event = "kill statement fallback"
+)
+
+// LogEntry is a structured log event.
+// Fields which do not apply to the event are left empty.
+type LogEntry struct {
+	Event        Event
+	ConnectionID ConnectionID
+	// Query is the fingerprint of the query concerned.
+	Query string
+	// Statement is the kill statement sent to the server.
+	Statement string
+	// Latency is the round-trip time of the kill statement.
+	Latency time.Duration
+	// Age is the time the query had been running for.
+	Age time.Duration
+	// Path is the way the kill reached the server.
+	Path KillPath
+	// Cause is the reason the context of the query was canceled.
+	Cause error
+	Err   error
+}
+
+// Logger receives the events of a Connector.
+// Implementations must be safe for concurrent use.
+type Logger interface {
+	Log(ctx context.Context, entry LogEntry)
+}
+
+// LoggerFunc adapts an ordinary function to the Logger interface.
+type LoggerFunc func(ctx context.Context, entry LogEntry)
+
+// Log calls f(ctx, entry).
+func (f LoggerFunc) Log(ctx context.Context, entry LogEntry) {
+	f(ctx, entry)
+}
+
+// WithLogger sets the logger of the connector.
+// The logger is used regardless of the debug setting.
+func WithLogger(logger Logger) Option {
+	return func(c *Connector) {
+		c.logger = logger
+	}
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Log(context.Context, LogEntry) {}
+
+type stdLogger struct {
+	l *log.Logger
+}
+
+// NewStdLogger returns a Logger which writes entries to l as key=value pairs.
+// A nil l writes to the standard logger.
+func NewStdLogger(l *log.Logger) Logger {
+	if l == nil {
+		l = log.Default()
+	}
+	return stdLogger{l}
+}
+
+func (s stdLogger) Log(_ context.Context, entry LogEntry) {
+	var b strings.Builder
+	b.WriteString("mysqlc: ")
+	b.WriteString(string(entry.Event))
+	if entry.ConnectionID != 0 {
+		writeLogField(&b, "connection_id", entry.ConnectionID.String())
+	}
+	if entry.Statement != "" {
+		writeLogField(&b, "statement", strconv.Quote(entry.Statement))
+	}
+	if entry.Query != "" {
+		writeLogField(&b, "query", strconv.Quote(entry.Query))
+	}
+	if entry.Latency > 0 {
+		writeLogField(&b, "latency", entry.Latency.String())
+	}
+	if entry.Age > 0 {
+		writeLogField(&b, "age", entry.Age.String())
+	}
+	if entry.Path != "" {
+		writeLogField(&b, "path", string(entry.Path))
+	}
+	if entry.Cause != nil {
+		writeLogField(&b, "cause", strconv.Quote(entry.Cause.Error()))
+	}
+	if entry.Err != nil {
+		writeLogField(&b, "error", strconv.Quote(entry.Err.Error()))
+	}
+	s.l.Print(b.String())
+}
+
+func writeLogField(b *strings.Builder, key, value string) {
+	b.WriteByte(' ')
+	b.WriteString(key)
+	b.WriteByte('=')
+	b.WriteString(value)
+}
+
+// contextCause returns the reason ctx was canceled, or nil.
+func contextCause(ctx context.Context) error {
+	if ctx == nil {
+		return nil
+	}
+	return context.Cause(ctx)
+}
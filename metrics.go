@@ -0,0 +1,68 @@
+package sql
+
+import (
+	"database/sql"
+	"time"
+)
+
+// MetricLabels identify what a metric is about.
+type MetricLabels struct {
+	// Connector is the name of the connector, see WithName.
+	Connector string
+	// Statement is the class of the statement concerned,
+	// or empty when it is not known.
+	Statement StatementClass
+}
+
+// MetricsSink receives the metrics of a Connector.
+// Implementations must be safe for concurrent use.
+type MetricsSink interface {
+	// QueryCancelled is called when a query is killed because
+	// its context was canceled. Kills by the watchdog or
+	// Connector.Cancel are not counted.
+	QueryCancelled(labels MetricLabels)
+	// KillIssued is called when a kill statement succeeded,
+	// with the round-trip time of the statement.
+	KillIssued(labels MetricLabels, latency time.Duration)
+	// KillFailed is called when a kill statement failed.
+	KillFailed(labels MetricLabels)
+	// KillSkipped is called when a kill was skipped because
+	// the query had already finished.
+	KillSkipped(labels MetricLabels)
+	// ConnectionIDLookup is called when the connection ID of a new
+	// connection was looked up, with the error of the lookup.
+	ConnectionIDLookup(labels MetricLabels, err error)
+}
+
+// KillPoolObserver is implemented by a MetricsSink which reports
+// the statistics of kill pools.
+type KillPoolObserver interface {
+	// ObserveKillPool is called once per connector with a function
+	// returning the current statistics of its kill pool. The connector
+	// calls unregister when it is closed, after which stats must no
+	// longer be called.
+	ObserveKillPool(connector string, stats func() sql.DBStats) (unregister func())
+}
+
+// WithMetrics sets the metrics sink of the connector.
+func WithMetrics(sink MetricsSink) Option {
+	return func(c *Connector) {
+		c.metrics = sink
+	}
+}
+
+// WithName sets the name of the connector used to label its metrics.
+// It defaults to the address of the server.
+func WithName(name string) Option {
+	return func(c *Connector) {
+		c.name = name
+	}
+}
+
+type nopMetrics struct{}
+
+func (nopMetrics) QueryCancelled(MetricLabels)            {}
+func (nopMetrics) KillIssued(MetricLabels, time.Duration) {}
+func (nopMetrics) KillFailed(MetricLabels)                {}
+func (nopMetrics) KillSkipped(MetricLabels)               {}
+func (nopMetrics) ConnectionIDLookup(MetricLabels, error) {}
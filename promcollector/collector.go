@@ -0,0 +1,186 @@
+// Package promcollector exports the metrics of mysqlc connectors to Prometheus.
+//
+//	var collector = promcollector.New("myapp")
+//	prometheus.MustRegister(collector)
+//
+//	connector, err := mysqlc.NewConnector(cfg, mysqlc.WithMetrics(collector), mysqlc.WithName("orders"))
+package promcollector
+
+import (
+	"database/sql"
+	"sync"
+	"time"
+
+	mysqlc "github.com/dati-mipt/mysql-go"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const subsystem = "mysqlc"
+
+// Collector is a mysqlc.MetricsSink and a prometheus.Collector.
+// One Collector can be shared by several connectors, which are told
+// apart by the connector label.
+type Collector struct {
+	queriesCancelled    *prometheus.CounterVec
+	killsIssued         *prometheus.CounterVec
+	killsFailed         *prometheus.CounterVec
+	killsSkipped        *prometheus.CounterVec
+	killLatency         *prometheus.HistogramVec
+	connectionIDLookups *prometheus.CounterVec
+
+	killPoolInUse *prometheus.Desc
+	killPoolIdle  *prometheus.Desc
+
+	mu    sync.Mutex
+	pools map[*observedPool]struct{}
+}
+
+// observedPool is the kill pool of a connector.
+type observedPool struct {
+	connector string
+	stats     func() sql.DBStats
+}
+
+var (
+	_ mysqlc.MetricsSink      = (*Collector)(nil)
+	_ mysqlc.KillPoolObserver = (*Collector)(nil)
+	_ prometheus.Collector    = (*Collector)(nil)
+)
+
+// New returns a Collector whose metrics are prefixed with namespace.
+func New(namespace string) *Collector {
+	var statementLabels = []string{"connector", "statement"}
+
+	return &Collector{
+		queriesCancelled: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "queries_cancelled_total",
+			Help:      "Queries killed because their context was canceled.",
+		}, statementLabels),
+		killsIssued: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "kills_issued_total",
+			Help:      "Kill statements executed successfully.",
+		}, statementLabels),
+		killsFailed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "kills_failed_total",
+			Help:      "Kill statements which failed.",
+		}, statementLabels),
+		killsSkipped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "kills_skipped_total",
+			Help:      "Kills skipped because the query had already finished.",
+		}, statementLabels),
+		killLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "kill_latency_seconds",
+			Help:      "Round-trip time of successful kill statements.",
+			Buckets:   prometheus.DefBuckets,
+		}, statementLabels),
+		connectionIDLookups: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "connection_id_lookups_total",
+			Help:      "Connection ID lookups of new connections.",
+		}, []string{"connector", "result"}),
+		killPoolInUse: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "kill_pool_in_use_connections"),
+			"Connections of the kill pool currently in use.",
+			[]string{"connector"}, nil,
+		),
+		killPoolIdle: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "kill_pool_idle_connections"),
+			"Idle connections of the kill pool.",
+			[]string{"connector"}, nil,
+		),
+		pools: make(map[*observedPool]struct{}),
+	}
+}
+
+// QueryCancelled implements mysqlc.MetricsSink.
+func (c *Collector) QueryCancelled(labels mysqlc.MetricLabels) {
+	c.queriesCancelled.WithLabelValues(labels.Connector, string(labels.Statement)).Inc()
+}
+
+// KillIssued implements mysqlc.MetricsSink.
+func (c *Collector) KillIssued(labels mysqlc.MetricLabels, latency time.Duration) {
+	c.killsIssued.WithLabelValues(labels.Connector, string(labels.Statement)).Inc()
+	c.killLatency.WithLabelValues(labels.Connector, string(labels.Statement)).Observe(latency.Seconds())
+}
+
+// KillFailed implements mysqlc.MetricsSink.
+func (c *Collector) KillFailed(labels mysqlc.MetricLabels) {
+	c.killsFailed.WithLabelValues(labels.Connector, string(labels.Statement)).Inc()
+}
+
+// KillSkipped implements mysqlc.MetricsSink.
+func (c *Collector) KillSkipped(labels mysqlc.MetricLabels) {
+	c.killsSkipped.WithLabelValues(labels.Connector, string(labels.Statement)).Inc()
+}
+
+// ConnectionIDLookup implements mysqlc.MetricsSink.
+func (c *Collector) ConnectionIDLookup(labels mysqlc.MetricLabels, err error) {
+	var result = "ok"
+	if err != nil {
+		result = "error"
+	}
+	c.connectionIDLookups.WithLabelValues(labels.Connector, result).Inc()
+}
+
+// ObserveKillPool implements mysqlc.KillPoolObserver.
+func (c *Collector) ObserveKillPool(connector string, stats func() sql.DBStats) func() {
+	var pool = &observedPool{connector: connector, stats: stats}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pools[pool] = struct{}{}
+
+	return func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		delete(c.pools, pool)
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	c.queriesCancelled.Describe(ch)
+	c.killsIssued.Describe(ch)
+	c.killsFailed.Describe(ch)
+	c.killsSkipped.Describe(ch)
+	c.killLatency.Describe(ch)
+	c.connectionIDLookups.Describe(ch)
+	ch <- c.killPoolInUse
+	ch <- c.killPoolIdle
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.queriesCancelled.Collect(ch)
+	c.killsIssued.Collect(ch)
+	c.killsFailed.Collect(ch)
+	c.killsSkipped.Collect(ch)
+	c.killLatency.Collect(ch)
+	c.connectionIDLookups.Collect(ch)
+
+	// Connectors sharing a name are reported together.
+	var inUse, idle = make(map[string]int), make(map[string]int)
+	c.mu.Lock()
+	for pool := range c.pools {
+		var s = pool.stats()
+		inUse[pool.connector] += s.InUse
+		idle[pool.connector] += s.Idle
+	}
+	c.mu.Unlock()
+
+	for connector := range inUse {
+		ch <- prometheus.MustNewConstMetric(c.killPoolInUse, prometheus.GaugeValue, float64(inUse[connector]), connector)
+		ch <- prometheus.MustNewConstMetric(c.killPoolIdle, prometheus.GaugeValue, float64(idle[connector]), connector)
+	}
+}
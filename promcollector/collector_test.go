@@ -0,0 +1,71 @@
+package promcollector
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	mysqlc "github.com/dati-mipt/mysql-go"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCollector(t *testing.T) {
+	var c = New("test")
+	var registry = prometheus.NewPedanticRegistry()
+	if err := registry.Register(c); err != nil {
+		t.Fatal(err)
+	}
+
+	var labels = mysqlc.MetricLabels{Connector: "orders", Statement: mysqlc.StatementSelect}
+	c.QueryCancelled(labels)
+	c.KillIssued(labels, 3*time.Millisecond)
+	c.KillFailed(labels)
+	c.ConnectionIDLookup(mysqlc.MetricLabels{Connector: "orders"}, errors.New("denied"))
+	c.ObserveKillPool("orders", func() sql.DBStats {
+		return sql.DBStats{InUse: 1, Idle: 2}
+	})
+
+	if got := testutil.ToFloat64(c.killsIssued.WithLabelValues("orders", "select")); got != 1 {
+		t.Errorf("kills issued = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(c.connectionIDLookups.WithLabelValues("orders", "error")); got != 1 {
+		t.Errorf("failed lookups = %v, want 1", got)
+	}
+
+	var families, err = registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names = make(map[string]bool)
+	for _, f := range families {
+		names[f.GetName()] = true
+	}
+	for _, name := range []string{
+		"test_mysqlc_queries_cancelled_total",
+		"test_mysqlc_kill_latency_seconds",
+		"test_mysqlc_kill_pool_in_use_connections",
+		"test_mysqlc_kill_pool_idle_connections",
+	} {
+		if !names[name] {
+			t.Errorf("metric %s not gathered", name)
+		}
+	}
+}
+
+func TestCollectorUnregistersClosedConnector(t *testing.T) {
+	var c = New("test")
+	var connector, err = mysqlc.NewConnector(mysqlc.NewConfig(), mysqlc.WithMetrics(c), mysqlc.WithName("orders"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := testutil.CollectAndCount(c, "test_mysqlc_kill_pool_idle_connections"); got != 1 {
+		t.Errorf("kill pools = %d, want 1", got)
+	}
+
+	connector.Close()
+	if got := testutil.CollectAndCount(c, "test_mysqlc_kill_pool_idle_connections"); got != 0 {
+		t.Errorf("kill pools = %d after the connector closed, want 0", got)
+	}
+}
@@ -0,0 +1,150 @@
+package sql
+
+import (
+	"strings"
+	"unicode"
+)
+
+// fingerprint returns query with its string and numeric literals replaced
+// by ? and its whitespace collapsed, so that executions of a query which
+// differ only in their literals share a fingerprint.
+func fingerprint(query string) string {
+	var b strings.Builder
+	b.Grow(len(query))
+
+	var space = false
+	for i := 0; i < len(query); i++ {
+		var ch = query[i]
+		switch {
+		case ch == '\'' || ch == '"':
+			i = skipQuoted(query, i)
+			writeFingerprintToken(&b, &space, "?")
+		case ch >= '0' && ch <= '9' && !inIdentifier(query, i):
+			for i+1 < len(query) && (isDigit(query[i+1]) || query[i+1] == '.') {
+				i++
+			}
+			writeFingerprintToken(&b, &space, "?")
+		case ch == '`':
+			var end = skipQuoted(query, i)
+			if end == len(query) {
+				end--
+			}
+			writeFingerprintToken(&b, &space, query[i:end+1])
+			i = end
+		case unicode.IsSpace(rune(ch)):
+			space = b.Len() > 0
+		default:
+			writeFingerprintToken(&b, &space, string(ch))
+		}
+	}
+
+	return b.String()
+}
+
+func writeFingerprintToken(b *strings.Builder, space *bool, token string) {
+	if *space {
+		b.WriteByte(' ')
+		*space = false
+	}
+	b.WriteString(token)
+}
+
+// skipQuoted returns the index of the quote closing the quoted
+// string starting at query[start].
+func skipQuoted(query string, start int) int {
+	var quote = query[start]
+	for i := start + 1; i < len(query); i++ {
+		switch query[i] {
+		case '\\':
+			i++
+		case quote:
+			if i+1 < len(query) && query[i+1] == quote {
+				i++
+				continue
+			}
+			return i
+		}
+	}
+	return len(query)
+}
+
+// inIdentifier reports whether the digit at query[i] is part of an identifier.
+func inIdentifier(query string, i int) bool {
+	if i == 0 {
+		return false
+	}
+	var prev = query[i-1]
+	return prev == '_' || prev == '$' || isDigit(prev) || unicode.IsLetter(rune(prev))
+}
+
+func isDigit(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
+
+// markQuery prefixes query with marker, if any.
+func markQuery(query string, marker string) string {
+	if marker == "" {
+		return query
+	}
+	return marker + " " + query
+}
+
+// StatementClass is the kind of a SQL statement.
+type StatementClass string
+
+const (
+	StatementSelect StatementClass = "select"
+	StatementDML    StatementClass = "dml"
+	StatementDDL    StatementClass = "ddl"
+	StatementOther  StatementClass = "other"
+)
+
+// classify returns the class of query judging by its first keyword.
+func classify(query string) StatementClass {
+	switch strings.ToUpper(firstKeyword(query)) {
+	case "SELECT", "WITH":
+		return StatementSelect
+	case "INSERT", "UPDATE", "DELETE", "REPLACE", "LOAD":
+		return StatementDML
+	case "CREATE", "ALTER", "DROP", "TRUNCATE", "RENAME":
+		return StatementDDL
+	}
+	return StatementOther
+}
+
+// firstKeyword returns the first word of query, skipping
+// leading whitespace, comments and parentheses.
+func firstKeyword(query string) string {
+	var start, end = firstKeywordSpan(query)
+	return query[start:end]
+}
+
+// firstKeywordSpan returns the bounds of the first keyword of query.
+func firstKeywordSpan(query string) (int, int) {
+	var i = 0
+	for i < len(query) {
+		switch {
+		case unicode.IsSpace(rune(query[i])) || query[i] == '(':
+			i++
+		case strings.HasPrefix(query[i:], "/*"):
+			var end = strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				return len(query), len(query)
+			}
+			i += 2 + end + 2
+		case strings.HasPrefix(query[i:], "-- ") || query[i] == '#':
+			var end = strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				return len(query), len(query)
+			}
+			i += end + 1
+		default:
+			var start = i
+			for i < len(query) && (unicode.IsLetter(rune(query[i])) || query[i] == '_') {
+				i++
+			}
+			return start, i
+		}
+	}
+	return len(query), len(query)
+}
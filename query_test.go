@@ -0,0 +1,21 @@
+package sql
+
+import "testing"
+
+func TestFingerprint(t *testing.T) {
+	var tests = []struct {
+		query string
+		want  string
+	}{
+		{"SELECT * FROM t WHERE id = 42", "SELECT * FROM t WHERE id = ?"},
+		{"select  a,\n\tb from t1 where s = 'it''s' and d = \"x\\\"y\"", "select a, b from t1 where s = ? and d = ?"},
+		{"SELECT `col 1` FROM t2 WHERE x IN (1, 2.5)", "SELECT `col 1` FROM t2 WHERE x IN (?, ?)"},
+		{"  KILL QUERY 12  ", "KILL QUERY ?"},
+	}
+
+	for _, tt := range tests {
+		if got := fingerprint(tt.query); got != tt.want {
+			t.Errorf("fingerprint(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package sql
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// KillPath is the way a kill reached the server.
+type KillPath string
+
+const (
+	// KillPathPool is a kill sent through the kill pool.
+	KillPathPool KillPath = "pool"
+	// KillPathReserved is a kill sent through the reserved kill connection,
+	// after the kill pool failed.
+	KillPathReserved KillPath = "reserved"
+	// KillPathClose is the closing of the socket of the data connection,
+	// after the kill statements could not be sent. The statement may keep
+	// running on the server, but its caller returns.
+	KillPathClose KillPath = "close"
+)
+
+// ErrConnectionClosed is returned by a kill which could not be sent, after
+// the socket of the connection was closed instead. It wraps the error of
+// the kill. The statement may keep running on the server.
+var ErrConnectionClosed = errors.New("sql: kill failed, connection closed")
+
+// reservedConn is a kill connection opened ahead of the first kill and
+// kept out of the kill pool, so that a kill can be sent when the pool can
+// not get a connection, e.g. because the server ran out of connections.
+type reservedConn struct {
+	connector driver.Connector
+	once      sync.Once
+
+	mu     sync.Mutex
+	conn   driver.Conn
+	closed bool
+}
+
+// warm opens the connection in the background, once.
+func (r *reservedConn) warm() {
+	if r == nil {
+		return
+	}
+	r.once.Do(func() {
+		go func() {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.open(context.Background())
+		}()
+	})
+}
+
+// open opens the connection unless it is open and valid.
+// r.mu must be held.
+func (r *reservedConn) open(ctx context.Context) error {
+	if r.closed {
+		return errKillPoolClosed
+	}
+	if r.conn != nil {
+		if v, ok := r.conn.(driver.Validator); !ok || v.IsValid() {
+			return nil
+		}
+		r.conn.Close()
+		r.conn = nil
+	}
+
+	var conn, err = r.connector.Connect(ctx)
+	if err != nil {
+		return err
+	}
+	r.conn = conn
+	return nil
+}
+
+// ping pings the connection, reopening it if it went bad.
+func (r *reservedConn) ping(ctx context.Context) error {
+	if r == nil {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.open(ctx); err != nil {
+		return err
+	}
+	var err = r.conn.(driver.Pinger).Ping(ctx)
+	if err != nil {
+		r.conn.Close()
+		r.conn = nil
+	}
+	return err
+}
+
+// exec executes qry on the connection, reopening it once if it went bad.
+func (r *reservedConn) exec(qry string, timeout time.Duration) error {
+	if r == nil {
+		return errors.New("sql: no reserved kill connection")
+	}
+
+	var ctx = context.Background()
+	if timeout > 0 {
+		var cancelFunc context.CancelFunc
+		ctx, cancelFunc = context.WithTimeout(ctx, timeout)
+		defer cancelFunc()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if err = r.open(ctx); err != nil {
+			return err
+		}
+		_, err = r.conn.(driver.ExecerContext).ExecContext(ctx, qry, nil)
+		if !errors.Is(err, driver.ErrBadConn) && !errors.Is(err, mysql.ErrInvalidConn) {
+			return err
+		}
+		r.conn.Close()
+		r.conn = nil
+	}
+	return err
+}
+
+// close closes the connection, which is not reopened.
+func (r *reservedConn) close() {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+	if r.conn != nil {
+		r.conn.Close()
+		r.conn = nil
+	}
+}
@@ -0,0 +1,158 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// failingConnector can not connect, like a server out of connections.
+type failingConnector struct{}
+
+func (failingConnector) Connect(context.Context) (driver.Conn, error) {
+	return nil, &mysql.MySQLError{Number: 1040, Message: "Too many connections"}
+}
+
+func (failingConnector) Driver() driver.Driver {
+	return nil
+}
+
+// entryRecorder is a Logger remembering the entries of an event.
+type entryRecorder struct {
+	event   Event
+	mu      sync.Mutex
+	entries []LogEntry
+}
+
+func (r *entryRecorder) Log(_ context.Context, e LogEntry) {
+	if e.Event == r.event {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.entries = append(r.entries, e)
+	}
+}
+
+func (r *entryRecorder) recorded() []LogEntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]LogEntry(nil), r.entries...)
+}
+
+func TestKillFallsBackToReservedConn(t *testing.T) {
+	var server = newFakeServer()
+	var logger = &entryRecorder{event: EventKillSent}
+	var c = newTestConnector(t, server, WithLogger(logger))
+	c.killer.pool.Close()
+	c.killer.pool.DB = sql.OpenDB(failingConnector{})
+	var conn = connect(t, c)
+
+	var ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	if _, err := conn.ExecContext(ctx, "SLEEP", nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want %v", err, context.Canceled)
+	}
+	if kills := server.killStatements(); len(kills) != 1 {
+		t.Errorf("kills = %v, want one", kills)
+	}
+	if entries := logger.recorded(); len(entries) != 1 || entries[0].Path != KillPathReserved {
+		t.Errorf("kills sent = %+v, want one through the reserved connection", entries)
+	}
+}
+
+func TestKillErrorNotRetried(t *testing.T) {
+	var server = newFakeServer()
+	server.killErr = &mysql.MySQLError{Number: 1095, Message: "You are not owner of thread"}
+	var logger = &entryRecorder{event: EventKillFailed}
+	var c = newTestConnector(t, server, WithLogger(logger))
+	var conn = connect(t, c)
+
+	var ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	conn.ExecContext(ctx, "SLEEP", nil)
+	if kills := server.killStatements(); len(kills) != 1 {
+		t.Errorf("kills = %v, want one", kills)
+	}
+	var entries = logger.recorded()
+	if len(entries) != 1 || entries[0].Path != KillPathPool || !errors.Is(entries[0].Err, server.killErr) {
+		t.Errorf("failed kills = %+v, want one through the kill pool with %v", entries, server.killErr)
+	}
+}
+
+func TestKillClosesSocket(t *testing.T) {
+	var server = newFakeServer()
+	var logger = &entryRecorder{event: EventConnectionClosed}
+	var c = newTestConnector(t, server, WithLogger(logger))
+	c.killer.pool.Close()
+	c.killer.pool.DB = sql.OpenDB(failingConnector{})
+	c.killer.pool.reserve = &reservedConn{connector: failingConnector{}}
+	var conn = connect(t, c)
+
+	var client, peer = net.Pipe()
+	defer peer.Close()
+	conn.netConn = client
+
+	var ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	var info = &QueryInfo{Query: "SLEEP", ConnectionID: conn.connectionID}
+	var w, err = conn.watch(ctx, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !w.kill(ctx, conn, info) {
+		t.Fatal("query not killed by the watch")
+	}
+	var cerr = w.cancelError(ctx, conn, errInterrupted)
+	if cerr.Killed || cerr.KillPath != KillPathClose || !errors.Is(cerr.KillErr, ErrConnectionClosed) {
+		t.Errorf("CancelError = %+v, want the socket closed instead of a kill", cerr)
+	}
+	if _, err := peer.Read(make([]byte, 1)); err == nil {
+		t.Error("socket still open")
+	}
+	if entries := logger.recorded(); len(entries) != 1 || entries[0].Path != KillPathClose || entries[0].Err == nil {
+		t.Errorf("closed = %+v, want one entry with the kill error", entries)
+	}
+	if conn.IsValid() {
+		t.Error("connection with a closed socket is still valid")
+	}
+}
+
+func TestKillFailsWithoutSocket(t *testing.T) {
+	var server = newFakeServer()
+	var c = newTestConnector(t, server)
+	c.killer.pool.Close()
+	c.killer.pool.DB = sql.OpenDB(failingConnector{})
+	c.killer.pool.reserve = &reservedConn{connector: failingConnector{}}
+	var conn = connect(t, c)
+	// The socket is not known for a network other than TCP and unix sockets.
+	conn.netConn = nil
+
+	var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	var errs = make(chan error, 1)
+	go func() {
+		var _, err = conn.ExecContext(ctx, "SLEEP", nil)
+		errs <- err
+	}()
+
+	select {
+	case err := <-errs:
+		var cerr *CancelError
+		if !errors.As(err, &cerr) || cerr.Killed || cerr.KillPath != KillPathClose || !errors.Is(cerr.KillErr, ErrConnectionClosed) {
+			t.Errorf("err = %v, want the connection closed instead of a kill", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("ExecContext still blocked after the kill failed")
+	}
+	if conn.IsValid() {
+		t.Error("connection with a failed kill is still valid")
+	}
+}
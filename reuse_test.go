@@ -0,0 +1,109 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// killSleep runs a sleeping statement on conn and cancels it.
+func killSleep(t *testing.T, conn *cancellableMysqlConn) {
+	t.Helper()
+
+	var ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	if _, err := conn.ExecContext(ctx, "SLEEP", nil); !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestResetSessionAfterKill(t *testing.T) {
+	var server = newFakeServer()
+	var conn = connect(t, newTestConnector(t, server))
+
+	if err := conn.ResetSession(context.Background()); err != nil {
+		t.Fatalf("ResetSession before the kill = %v, want none", err)
+	}
+	killSleep(t, conn)
+	if err := conn.ResetSession(context.Background()); err != driver.ErrBadConn {
+		t.Errorf("ResetSession after the kill = %v, want %v", err, driver.ErrBadConn)
+	}
+}
+
+func TestFailedKillInvalidatesConn(t *testing.T) {
+	var server = newFakeServer()
+	server.killErr = &mysql.MySQLError{Number: 1094, Message: "Unknown thread id"}
+	var conn = connect(t, newTestConnector(t, server))
+
+	killSleep(t, conn)
+	if conn.IsValid() {
+		t.Error("connection is still valid after a failed kill")
+	}
+	if err := conn.ResetSession(context.Background()); err != driver.ErrBadConn {
+		t.Errorf("ResetSession = %v, want %v", err, driver.ErrBadConn)
+	}
+}
+
+func TestPingOnReuse(t *testing.T) {
+	var server = newFakeServer()
+	var conn = connect(t, newTestConnector(t, server, WithPingOnReuse(true)))
+
+	if err := conn.ResetSession(context.Background()); err != nil {
+		t.Fatalf("ResetSession = %v, want none", err)
+	}
+
+	server.pingErr = mysql.ErrInvalidConn
+	if err := conn.ResetSession(context.Background()); err != driver.ErrBadConn {
+		t.Errorf("ResetSession = %v, want %v", err, driver.ErrBadConn)
+	}
+	if conn.IsValid() {
+		t.Error("connection which failed the ping is still valid")
+	}
+}
+
+func TestPoolReconnectsAfterKill(t *testing.T) {
+	var server = newFakeServer()
+	var db = sql.OpenDB(newTestConnector(t, server))
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	// connectionID returns the connection ID of the pooled connection.
+	var connectionID = func() ConnectionID {
+		t.Helper()
+		var conn, err = db.Conn(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+
+		var id ConnectionID
+		conn.Raw(func(driverConn interface{}) error {
+			id = driverConn.(*cancellableMysqlConn).connectionID
+			return nil
+		})
+		return id
+	}
+
+	var first = connectionID()
+	if id := connectionID(); id != first {
+		t.Fatalf("connection ID = %v, want the pooled connection %v", id, first)
+	}
+
+	var ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	if _, err := db.ExecContext(ctx, "SLEEP"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want %v", err, context.Canceled)
+	}
+
+	if id := connectionID(); id == first {
+		t.Errorf("connection ID = %v, want a new connection after the kill", id)
+	}
+	if kills := server.killStatements(); len(kills) != 1 || kills[0] != "KILL QUERY "+first.String() {
+		t.Errorf("kills = %v, want [KILL QUERY %s]", kills, first)
+	}
+}
@@ -2,47 +2,160 @@ package sql
 
 import (
 	"context"
-	"database/sql"
 	"database/sql/driver"
+	"io"
 	"reflect"
-	"time"
 )
 
 type cancellableMysqlRows struct {
-	ctx          context.Context
-	rows         driver.Rows
-	killerPool   *sql.DB
-	connectionID string
-	kto          time.Duration
+	ctx  context.Context
+	rows driver.Rows
+	conn *cancellableMysqlConn
+	info *QueryInfo
+
+	// limited is set if the execution time of the session was limited
+	// for the query and must be reset once its rows are read.
+	limited bool
+
+	// watch kills the query once ctx is done while the rows are read.
+	// It is nil if the query can not be killed.
+	watch *killWatch
+	// eof is set once Next reached the end of the result set.
+	eof bool
+	// cancelFunc, if set, releases the deadline the statement class
+	// set on ctx.
+	cancelFunc context.CancelFunc
 }
 
 func (rs *cancellableMysqlRows) Columns() []string {
 	var cols = rs.rows.Columns()
 	if rs.ctx.Err() != nil {
-		kill(rs.killerPool, rs.connectionID, rs.kto)
+		rs.kill()
 	}
 	return cols
 }
 
-// Unleak will release the reference to the killerPool
+// kill kills the query of the rows, whose context is done.
+// It reports whether the query was killed.
+func (rs *cancellableMysqlRows) kill() bool {
+	if rs.watch != nil {
+		return rs.watch.cancel(rs.ctx, rs.conn, rs.info)
+	}
+	rs.conn.kill(rs.ctx, rs.info, nil)
+	return false
+}
+
+// Unleak will release the reference to the connection
 // in order to prevent a memory leak.
 func (rs *cancellableMysqlRows) Unleak() {
-	rs.killerPool = nil
-	rs.connectionID = ""
-	rs.kto = 0
+	rs.conn = nil
 }
 
 func (rs *cancellableMysqlRows) Close() error {
+	var closed bool
+	switch {
+	case rs.ctx.Err() != nil:
+		// Kill the query before the wrapped driver drains its rows.
+		rs.kill()
+	case rs.watch != nil && rs.info.ConnectionID != 0 && rs.conn.connector.cfg.killOnEarlyClose && !rs.finished() && !rs.drain():
+		closed = rs.killEarly()
+	case rs.watch != nil:
+		rs.watch.done()
+	}
+
 	err := rs.rows.Close()
-	if rs.ctx.Err() != nil {
-		kill(rs.killerPool, rs.connectionID, rs.kto)
+	if closed {
+		// The wrapped driver fails to drain the closed socket.
+		err = nil
+	}
+	if rs.conn != nil {
+		if rs.limited {
+			rs.conn.resetExecutionTime()
+		}
+		if rs.conn.connector != nil {
+			rs.conn.connector.queries.leave(rs.info)
+		}
+	}
+	if rs.cancelFunc != nil {
+		rs.cancelFunc()
 	}
 	rs.Unleak()
 	return err
 }
 
+// finished reports whether every row of the query was read.
+func (rs *cancellableMysqlRows) finished() bool {
+	return rs.eof && !rs.HasNextResultSet()
+}
+
+// drain reads the rows left, up to the killOnEarlyClose thresholds,
+// and reports whether the result set was read to its end.
+func (rs *cancellableMysqlRows) drain() bool {
+	var cfg = rs.conn.connector.cfg
+	var dest = make([]driver.Value, len(rs.rows.Columns()))
+	var size int
+	for n := 0; n < cfg.killOnEarlyCloseRows && size < cfg.killOnEarlyCloseBytes; n++ {
+		if err := rs.rows.Next(dest); err != nil {
+			// The wrapped driver reports other errors on Close.
+			return true
+		}
+		for _, v := range dest {
+			size += valueSize(v)
+		}
+	}
+	return false
+}
+
+// killEarly kills the unfinished query of the rows being closed and closes
+// the socket of its connection, so that the wrapped driver does not drain
+// the rows left. It reports whether the socket was closed.
+func (rs *cancellableMysqlRows) killEarly() bool {
+	if rs.watch.stop != nil {
+		rs.watch.stop()
+	}
+	if !rs.watch.kill(rs.ctx, rs.conn, rs.info) {
+		return false
+	}
+	if rs.watch.killPath == KillPathClose {
+		// The kill failed and closed the socket.
+		return true
+	}
+	if rs.watch.killErr != nil {
+		return false
+	}
+	return rs.watch.abort(rs.conn) == nil
+}
+
+// valueSize approximates the bytes v took on the wire.
+func valueSize(v driver.Value) int {
+	switch v := v.(type) {
+	case []byte:
+		return len(v)
+	case string:
+		return len(v)
+	default:
+		return 8
+	}
+}
+
+// Next reads the next row. Once the context is done, the query is killed
+// and Next returns a *CancelError instead of reading the remaining rows.
 func (rs *cancellableMysqlRows) Next(dest []driver.Value) error {
-	return rs.rows.Next(dest)
+	if rs.watch == nil {
+		return rs.rows.Next(dest)
+	}
+	if rs.ctx.Err() != nil && rs.kill() {
+		return rs.watch.cancelError(rs.ctx, rs.conn, nil)
+	}
+
+	var err = rs.rows.Next(dest)
+	if err == io.EOF {
+		rs.eof = true
+	}
+	if err != nil && err != io.EOF && rs.ctx.Err() != nil && rs.kill() {
+		return rs.watch.cancelError(rs.ctx, rs.conn, err)
+	}
+	return err
 }
 
 func (rs *cancellableMysqlRows) HasNextResultSet() bool {
@@ -56,6 +169,7 @@ func (rs *cancellableMysqlRows) HasNextResultSet() bool {
 
 func (rs *cancellableMysqlRows) NextResultSet() error {
 	var rowsNextResultSet = rs.rows.(driver.RowsNextResultSet)
+	rs.eof = false
 	return rowsNextResultSet.NextResultSet()
 }
 
@@ -0,0 +1,51 @@
+package sql
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+)
+
+func TestKillOnEarlyClose(t *testing.T) {
+	var server = newFakeServer()
+	var c = newTestConnector(t, server, WithKillOnEarlyClose(10, 0))
+	var conn = connect(t, c)
+
+	var rows, err = conn.QueryContext(context.Background(), "SELECT n FROM STREAM", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = rows.Next(make([]driver.Value, 1)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = rows.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if kills := server.killStatements(); len(kills) != 1 || kills[0] != "KILL QUERY "+conn.connectionID.String() {
+		t.Errorf("kills = %v, want [KILL QUERY %s]", kills, conn.connectionID)
+	}
+	if conn.IsValid() {
+		t.Error("connection of the killed rows is still valid")
+	}
+	awaitInFlight(t, c, 0)
+}
+
+func TestKillOnEarlyCloseDrainsShortResult(t *testing.T) {
+	var server = newFakeServer()
+	var conn = connect(t, newTestConnector(t, server, WithKillOnEarlyClose(10, 0)))
+
+	var rows, err = conn.QueryContext(context.Background(), "SELECT 1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = rows.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if kills := server.killStatements(); len(kills) != 0 {
+		t.Errorf("kills = %v, want none", kills)
+	}
+	if !conn.IsValid() {
+		t.Error("connection of the drained rows is invalid")
+	}
+}
@@ -0,0 +1,79 @@
+package sql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrShuttingDown is returned for the queries sent after Connector.Shutdown
+// was called.
+var ErrShuttingDown = errors.New("sql: connector shutting down")
+
+// ShutdownReport describes the queries which were in flight when
+// Connector.Shutdown was called.
+type ShutdownReport struct {
+	// Drained are the queries which completed before the deadline.
+	Drained []InFlightQuery
+	// Killed are the queries which were killed at the deadline, or
+	// refused their statement.
+	Killed []InFlightQuery
+}
+
+// shutdown stops queries from entering the registry and returns a channel
+// closed once the registry is empty.
+func (r *registry) shutdown() <-chan struct{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.drained == nil {
+		r.drained = make(chan struct{})
+		if len(r.queries) == 0 {
+			close(r.drained)
+		}
+	}
+	return r.drained
+}
+
+// Shutdown stops the connector from accepting new queries, which fail with
+// ErrShuttingDown, and waits for the in-flight queries to complete until
+// ctx is done. The queries still running then are killed through the kill
+// pool. The queries which entered before Shutdown but had not sent their
+// statement yet fail with ErrShuttingDown; those still in flight at the
+// deadline are reported as killed. It returns what was drained and what was
+// killed, and the errors of the kills which failed.
+//
+// Shutdown does not close the connector: close the sql.DB afterwards, which
+// no longer waits for running queries.
+func (c *Connector) Shutdown(ctx context.Context) (*ShutdownReport, error) {
+	var drained = c.queries.shutdown()
+	var inFlight = c.InFlight()
+
+	var survivors = make(map[QueryID]bool)
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		for _, q := range c.InFlight() {
+			survivors[q.ID] = true
+		}
+	}
+
+	var report = &ShutdownReport{}
+	var errs []error
+	for _, q := range inFlight {
+		if !survivors[q.ID] {
+			report.Drained = append(report.Drained, q)
+			continue
+		}
+		switch err := c.Cancel(q.ID); err {
+		case nil, ErrQueryNotSent:
+			// A statement not sent yet is refused, as the connector is shutting down.
+			report.Killed = append(report.Killed, q)
+		case ErrQueryNotFound, ErrQueryFinished:
+			// The query completed as the deadline passed.
+			report.Drained = append(report.Drained, q)
+		default:
+			errs = append(errs, fmt.Errorf("sql: kill query %d: %w", q.ID, err))
+		}
+	}
+	return report, errors.Join(errs...)
+}
@@ -0,0 +1,87 @@
+package sql
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestShutdown(t *testing.T) {
+	var server = newFakeServer()
+	var c = newTestConnector(t, server)
+	var sleeping, reading = connect(t, c), connect(t, c)
+
+	var errs = make(chan error, 1)
+	go func() {
+		var _, err = sleeping.ExecContext(context.Background(), "SLEEP", nil)
+		errs <- err
+	}()
+	var rows, err = reading.QueryContext(context.Background(), "SELECT 1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	awaitInFlight(t, c, 2)
+
+	var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	time.AfterFunc(10*time.Millisecond, func() { rows.Close() })
+
+	var report *ShutdownReport
+	if report, err = c.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Drained) != 1 || report.Drained[0].ConnectionID != reading.connectionID {
+		t.Errorf("drained = %+v, want the closed rows", report.Drained)
+	}
+	if len(report.Killed) != 1 || report.Killed[0].ConnectionID != sleeping.connectionID {
+		t.Errorf("killed = %+v, want the sleeping statement", report.Killed)
+	}
+	if err = <-errs; !isInterrupted(err) {
+		t.Errorf("err = %v, want the interrupted statement", err)
+	}
+
+	if _, err = reading.ExecContext(context.Background(), "DO 1", nil); err != ErrShuttingDown {
+		t.Errorf("err = %v, want %v", err, ErrShuttingDown)
+	}
+}
+
+func TestShutdownIdle(t *testing.T) {
+	var c = newTestConnector(t, newFakeServer())
+
+	var report, err = c.Shutdown(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Drained) != 0 || len(report.Killed) != 0 {
+		t.Errorf("report = %+v, want empty", report)
+	}
+}
+
+func TestShutdownUnsentQuery(t *testing.T) {
+	var server = newFakeServer()
+	var c = newTestConnector(t, server)
+	var conn = connect(t, c)
+
+	// The query entered the registry, but its statement is not sent yet.
+	var info = &QueryInfo{Query: "SLEEP", ConnectionID: conn.connectionID}
+	if err := c.queries.enter(context.Background(), conn, info); err != nil {
+		t.Fatal(err)
+	}
+	defer c.queries.leave(info)
+
+	var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	var report, err = c.Shutdown(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Drained) != 0 || len(report.Killed) != 1 || report.Killed[0].ID != info.id {
+		t.Errorf("report = %+v, want the unsent query killed", report)
+	}
+	if _, err = conn.watch(context.Background(), info); err != ErrShuttingDown {
+		t.Errorf("sending the statement = %v, want %v", err, ErrShuttingDown)
+	}
+	if kills := server.killStatements(); len(kills) != 0 {
+		t.Errorf("kills = %v, want none", kills)
+	}
+}
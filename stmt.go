@@ -4,26 +4,27 @@ package sql
 
 import (
 	"context"
-	"database/sql"
 	"database/sql/driver"
-	"time"
+
+	"go.opentelemetry.io/otel/trace"
 )
 
 // cancellableMysqlStfmt is a prepared statement.
 // A cancellableMysqlStfmt is safe for concurrent use by multiple goroutines.
 type cancellableMysqlStfmt struct {
-	stmt         driver.Stmt
-	killerPool   *sql.DB
-	connectionID string
-	kto          time.Duration
+	stmt  driver.Stmt
+	conn  *cancellableMysqlConn
+	query string
+	// marker identifies the statement on the server. It is sent once,
+	// when the statement is prepared, so every execution shares it: a
+	// kill is verified per statement, not per execution.
+	marker string
 }
 
-// Unleak will release the reference to the killerPool
+// Unleak will release the reference to the connection
 // in order to prevent a memory leak.
 func (s *cancellableMysqlStfmt) Unleak() {
-	s.killerPool = nil
-	s.connectionID = ""
-	s.kto = 0
+	s.conn = nil
 }
 
 // Close closes the statement.
@@ -42,75 +43,159 @@ func (s *cancellableMysqlStfmt) NumInput() int {
 
 // Exec executes a prepared statement with the given arguments and
 // returns a Result summarizing the effect of the statement.
+// It can not be canceled, but the watchdog can kill it.
 func (s *cancellableMysqlStfmt) Exec(args []driver.Value) (driver.Result, error) {
-	return s.stmt.Exec(args)
+	var info = &QueryInfo{Query: s.query, Args: namedValues(args), ConnectionID: s.conn.connectionID, marker: s.marker}
+	if err := s.conn.connector.queries.enter(context.Background(), s.conn, info); err != nil {
+		return nil, err
+	}
+	defer s.conn.connector.queries.leave(info)
+	var w, err = s.conn.watch(context.Background(), info)
+	if err != nil {
+		return nil, err
+	}
+	defer w.done()
+
+	return s.stmt.(driver.StmtExecContext).ExecContext(w.driverCtx, info.Args)
 }
 
 // ExecContext executes a prepared statement with the given arguments and
 // returns a Result summarizing the effect of the statement.
-func (s *cancellableMysqlStfmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+func (s *cancellableMysqlStfmt) ExecContext(ctx context.Context, args []driver.NamedValue) (res driver.Result, err error) {
 	var stmtExecContext = s.stmt.(driver.StmtExecContext)
 
-	// Create a context that is used to cancel ExecContext()
-	cancelCtx, cancelFunc := context.WithCancel(context.Background())
+	var cancelFunc context.CancelFunc
+	ctx, cancelFunc = s.conn.connector.withQueryTimeout(ctx, s.query)
 	defer cancelFunc()
 
-	outChan := make(chan sql.Result)
-	errChan := make(chan error)
-	returnedChan := make(chan struct{}) // Used to indicate that this function has returned
-
-	defer func() {
-		returnedChan <- struct{}{}
-	}()
-
-	go func() {
-		select {
-		case <-ctx.Done():
-			// context has been canceled
-			kill(s.killerPool, s.connectionID, s.kto)
-			errChan <- ctx.Err()
-		case <-returnedChan:
-		}
-	}()
+	var info = &QueryInfo{Query: s.query, Args: args, ConnectionID: s.conn.connectionID, marker: s.marker}
+	if err = s.conn.connector.interceptors.beforeExec(ctx, info); err != nil {
+		return nil, err
+	}
+	args = info.Args
+	defer func() { s.conn.connector.interceptors.afterExec(ctx, info, err) }()
 
-	go func() {
-		res, err := stmtExecContext.ExecContext(cancelCtx, args)
-		if err != nil {
-			errChan <- err
-			return
+	if err = s.conn.connector.queries.enter(ctx, s.conn, info); err != nil {
+		return nil, err
+	}
+	defer s.conn.connector.queries.leave(info)
+
+	var span trace.Span
+	ctx, span = startSpan(ctx, s.conn.connector.tracer, "mysqlc.stmt.exec", s.conn.connectionID, s.query)
+	defer func() { endSpan(span, err) }()
+
+	if s.conn.connectionID == 0 {
+		// Without a connection ID the query can not be killed;
+		// leave the cancellation to the wrapped driver.
+		res, err = stmtExecContext.ExecContext(ctx, args)
+		if err != nil && ctx.Err() != nil {
+			return nil, s.conn.cancelError(ctx, err)
 		}
-		outChan <- res
-	}()
+		return res, err
+	}
 
-	select {
-	case err := <-errChan:
+	// See cancellableMysqlConn.ExecContext.
+	var w *killWatch
+	if w, err = s.conn.watch(ctx, info); err != nil {
 		return nil, err
-	case out := <-outChan:
-		return out, nil
 	}
+	res, err = stmtExecContext.ExecContext(w.driverCtx, args)
+	if w.done() && err != nil {
+		return nil, w.cancelError(ctx, s.conn, err)
+	}
+	return res, err
 }
 
 // Query executes a prepared query statement with the given arguments
 // and returns the query results as a *cancellableMysqlRows.
+// It can not be canceled, but the watchdog can kill it.
 func (s *cancellableMysqlStfmt) Query(args []driver.Value) (driver.Rows, error) {
-	return s.stmt.Query(args)
+	var info = &QueryInfo{Query: s.query, Args: namedValues(args), ConnectionID: s.conn.connectionID, marker: s.marker}
+	if err := s.conn.connector.queries.enter(context.Background(), s.conn, info); err != nil {
+		return nil, err
+	}
+
+	var w, err = s.conn.watch(context.Background(), info)
+	if err != nil {
+		s.conn.connector.queries.leave(info)
+		return nil, err
+	}
+	var rows driver.Rows
+	if rows, err = s.stmt.(driver.StmtQueryContext).QueryContext(w.driverCtx, info.Args); err != nil {
+		w.done()
+		s.conn.connector.queries.leave(info)
+		return nil, err
+	}
+	return &cancellableMysqlRows{ctx: context.Background(), rows: rows, conn: s.conn, info: info, watch: w}, nil
 }
 
 // QueryContext executes a prepared query statement with the given arguments
 // and returns the query results as a *cancellableMysqlRows.
-func (s *cancellableMysqlStfmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+func (s *cancellableMysqlStfmt) QueryContext(ctx context.Context, args []driver.NamedValue) (_ driver.Rows, err error) {
 	var stmtQueryContext = s.stmt.(driver.StmtQueryContext)
 
-	// We can't use the same approach used in ExecContext because defer cancelFunc()
-	// cancels rows.Scan.
+	// See cancellableMysqlConn.QueryContext.
+	var cancelFunc context.CancelFunc
+	ctx, cancelFunc = s.conn.connector.withQueryTimeout(ctx, s.query)
+	defer func() {
+		if err != nil {
+			cancelFunc()
+		}
+	}()
+
+	var info = &QueryInfo{Query: s.query, Args: args, ConnectionID: s.conn.connectionID, marker: s.marker}
+	if err = s.conn.connector.interceptors.beforeQuery(ctx, info); err != nil {
+		return nil, err
+	}
+	args = info.Args
+	defer func() { s.conn.connector.interceptors.afterQuery(ctx, info, err) }()
+
+	// The query leaves the registry when its rows are closed.
+	if err = s.conn.connector.queries.enter(ctx, s.conn, info); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			s.conn.connector.queries.leave(info)
+		}
+	}()
+
+	var span trace.Span
+	ctx, span = startSpan(ctx, s.conn.connector.tracer, "mysqlc.stmt.query", s.conn.connectionID, s.query)
+	defer func() { endSpan(span, err) }()
+
+	var rows driver.Rows
+	var limited = s.conn.limitExecutionTime(ctx, s.query)
 	defer func() {
-		if ctx.Err() != nil {
-			kill(s.killerPool, s.connectionID, s.kto)
+		if err != nil && limited {
+			s.conn.resetExecutionTime()
 		}
 	}()
 
-	rows, err := stmtQueryContext.QueryContext(ctx, args)
-	return &cancellableMysqlRows{ctx: ctx, rows: rows, killerPool: s.killerPool, connectionID: s.connectionID}, err
+	if s.conn.connectionID == 0 {
+		// Without a connection ID the query can not be killed;
+		// leave the cancellation to the wrapped driver.
+		if rows, err = stmtQueryContext.QueryContext(ctx, args); err != nil {
+			if ctx.Err() != nil {
+				return nil, s.conn.cancelError(ctx, err)
+			}
+			return nil, err
+		}
+		return &cancellableMysqlRows{ctx: ctx, cancelFunc: cancelFunc, rows: rows, conn: s.conn, info: info, limited: limited}, nil
+	}
+
+	// See cancellableMysqlConn.QueryContext.
+	var w *killWatch
+	if w, err = s.conn.watch(ctx, info); err != nil {
+		return nil, err
+	}
+	if rows, err = stmtQueryContext.QueryContext(w.driverCtx, args); err != nil {
+		if w.done() {
+			return nil, w.cancelError(ctx, s.conn, err)
+		}
+		return nil, err
+	}
+	return &cancellableMysqlRows{ctx: ctx, cancelFunc: cancelFunc, rows: rows, conn: s.conn, info: info, limited: limited, watch: w}, nil
 }
 
 func (s *cancellableMysqlStfmt) ColumnConverter(idx int) driver.ValueConverter {
@@ -0,0 +1,37 @@
+package sql
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+type queryTimeoutKey struct{}
+
+// withQueryTimeout returns ctx with the deadline set by the timeout of the
+// statement class of query, unless ctx has an earlier deadline, and the
+// function releasing it. The cause of the context tells the timeout.
+func (c *Connector) withQueryTimeout(ctx context.Context, query string) (context.Context, context.CancelFunc) {
+	var class = classify(query)
+	var d = c.queryTimeout(class)
+	if d <= 0 {
+		return ctx, func() {}
+	}
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= d {
+		return ctx, func() {}
+	}
+
+	ctx = context.WithValue(ctx, queryTimeoutKey{}, d)
+	return context.WithTimeoutCause(ctx, d, timeoutError(class, d))
+}
+
+// contextTimeout returns the timeout withQueryTimeout set on ctx, or 0.
+func contextTimeout(ctx context.Context) time.Duration {
+	var d, _ = ctx.Value(queryTimeoutKey{}).(time.Duration)
+	return d
+}
+
+// timeoutError is the cause of a statement of class exceeding its timeout d.
+func timeoutError(class StatementClass, d time.Duration) error {
+	return fmt.Errorf("sql: %s statement exceeded its %s timeout", class, d)
+}
@@ -0,0 +1,70 @@
+package sql
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQueryTimeoutDeadline(t *testing.T) {
+	var server = newFakeServer()
+	var conn = connect(t, newTestConnector(t, server, WithClassTimeout(StatementOther, 20*time.Millisecond)))
+
+	var _, err = conn.ExecContext(context.Background(), "SLEEP", nil)
+	var cerr *CancelError
+	if !errors.As(err, &cerr) || !cerr.Killed || cerr.Timeout != 20*time.Millisecond {
+		t.Fatalf("err = %v, want a *CancelError of a query killed by its 20ms timeout", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "other statement exceeded its 20ms timeout") {
+		t.Errorf("err = %v, want the deadline of the statement class", err)
+	}
+	// The watchdog leaves the query to its context.
+	if kills := server.killStatements(); len(kills) != 1 {
+		t.Errorf("kills = %v, want one", kills)
+	}
+}
+
+func TestQueryTimeoutEarlierDeadline(t *testing.T) {
+	var server = newFakeServer()
+	var conn = connect(t, newTestConnector(t, server, WithClassTimeout(StatementOther, time.Minute)))
+
+	var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	var _, err = conn.ExecContext(ctx, "SLEEP", nil)
+	var cerr *CancelError
+	if !errors.As(err, &cerr) || cerr.Timeout != 0 {
+		t.Fatalf("err = %v, want a *CancelError of the deadline of the caller", err)
+	}
+}
+
+func TestQueryTimeoutClasses(t *testing.T) {
+	var cfg, err = ParseDSN("user@/dbname?queryTimeout=1m&selectTimeout=30s&ddlTimeout=1h")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var c *Connector
+	if c, err = NewConnector(cfg, WithClassTimeout(StatementDDL, 0)); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	var tests = []struct {
+		class StatementClass
+		want  time.Duration
+	}{
+		{StatementSelect, 30 * time.Second},
+		{StatementDML, time.Minute},
+		{StatementDDL, 0},
+		{StatementOther, time.Minute},
+	}
+	for _, test := range tests {
+		if d := c.queryTimeout(test.class); d != test.want {
+			t.Errorf("%s timeout = %v, want %v", test.class, d, test.want)
+		}
+	}
+	if _, ok := cfg.Params["selectTimeout"]; ok {
+		t.Error("selectTimeout is sent to the server as a system variable")
+	}
+}
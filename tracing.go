@@ -0,0 +1,42 @@
+package sql
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/dati-mipt/mysql-go"
+
+// WithTracerProvider enables tracing of queries and kills with the
+// tracers of tp. Statements are recorded by their fingerprint.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(c *Connector) {
+		c.tracer = tp.Tracer(tracerName)
+	}
+}
+
+// startSpan starts a client span named name for query.
+// The attributes are only computed for spans which are recorded.
+func startSpan(ctx context.Context, tracer trace.Tracer, name string, connectionID ConnectionID, query string) (context.Context, trace.Span) {
+	ctx, span := tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient))
+	if span.IsRecording() {
+		span.SetAttributes(
+			attribute.String("db.system", "mysql"),
+			attribute.String("db.statement", fingerprint(query)),
+			attribute.Int64("db.mysql.connection_id", int64(connectionID)),
+		)
+	}
+	return ctx, span
+}
+
+// endSpan records err, if any, and ends span.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
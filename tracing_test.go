@@ -0,0 +1,128 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// newSpanRecorder returns a tracer provider recording the ended spans.
+func newSpanRecorder() (*sdktrace.TracerProvider, *tracetest.SpanRecorder) {
+	var sr = tracetest.NewSpanRecorder()
+	return sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)), sr
+}
+
+// endedSpan returns the ended span named name.
+func endedSpan(t *testing.T, sr *tracetest.SpanRecorder, name string) sdktrace.ReadOnlySpan {
+	t.Helper()
+
+	for _, span := range sr.Ended() {
+		if span.Name() == name {
+			return span
+		}
+	}
+	t.Fatalf("no span %q", name)
+	return nil
+}
+
+// spanAttribute returns the value of the attribute key of span.
+func spanAttribute(span sdktrace.ReadOnlySpan, key attribute.Key) attribute.Value {
+	for _, kv := range span.Attributes() {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestTracingKill(t *testing.T) {
+	var server = newFakeServer()
+	var tp, sr = newSpanRecorder()
+	var conn = connect(t, newTestConnector(t, server, WithTracerProvider(tp)))
+
+	var ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	conn.ExecContext(ctx, "SLEEP 10", nil)
+
+	var query = endedSpan(t, sr, "mysqlc.exec")
+	var kill = endedSpan(t, sr, "mysqlc.kill")
+	var killExec = endedSpan(t, sr, "mysqlc.kill.exec")
+
+	if kill.Parent().SpanID() != query.SpanContext().SpanID() {
+		t.Errorf("kill span parent = %v, want the query span %v", kill.Parent().SpanID(), query.SpanContext().SpanID())
+	}
+	if killExec.Parent().SpanID() != kill.SpanContext().SpanID() {
+		t.Errorf("kill.exec span parent = %v, want the kill span %v", killExec.Parent().SpanID(), kill.SpanContext().SpanID())
+	}
+
+	for _, tt := range []struct {
+		span      sdktrace.ReadOnlySpan
+		statement string
+	}{
+		{query, fingerprint("SLEEP 10")},
+		{kill, fingerprint("SLEEP 10")},
+		{killExec, "KILL QUERY 1"},
+	} {
+		if system := spanAttribute(tt.span, "db.system").AsString(); system != "mysql" {
+			t.Errorf("%s: db.system = %q, want %q", tt.span.Name(), system, "mysql")
+		}
+		if statement := spanAttribute(tt.span, "db.statement").AsString(); statement != tt.statement {
+			t.Errorf("%s: db.statement = %q, want %q", tt.span.Name(), statement, tt.statement)
+		}
+	}
+	if path := spanAttribute(killExec, "mysqlc.kill_path").AsString(); path != string(KillPathPool) {
+		t.Errorf("kill path = %q, want %q", path, KillPathPool)
+	}
+}
+
+func TestTracingConnectionID(t *testing.T) {
+	var server = newFakeServer()
+	var tp, sr = newSpanRecorder()
+	var c = newTestConnector(t, server, WithTracerProvider(tp))
+
+	var ctx, span = tp.Tracer("test").Start(context.Background(), "connect")
+	var conn, err = c.Connect(ctx)
+	span.End()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	var events = endedSpan(t, sr, "connect").Events()
+	if len(events) != 1 || events[0].Name != "mysqlc.connection_id_resolved" {
+		t.Fatalf("events = %+v, want the resolved connection ID", events)
+	}
+	var id = conn.(*cancellableMysqlConn).connectionID
+	for _, kv := range events[0].Attributes {
+		if kv.Key == "db.mysql.connection_id" && kv.Value.AsInt64() != int64(id) {
+			t.Errorf("connection ID = %v, want %v", kv.Value.AsInt64(), id)
+		}
+	}
+}
+
+func TestTracingSkippedExec(t *testing.T) {
+	var server = newFakeServer()
+	server.skipArgs = true
+	var tp, sr = newSpanRecorder()
+	var db = sql.OpenDB(newTestConnector(t, server, WithTracerProvider(tp)))
+	defer db.Close()
+
+	// The driver skips the statement, which database/sql then prepares.
+	if _, err := db.ExecContext(context.Background(), "DO ?", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	endedSpan(t, sr, "mysqlc.exec")
+	endedSpan(t, sr, "mysqlc.stmt.exec")
+	for _, span := range sr.Ended() {
+		if span.Status().Code == codes.Error {
+			t.Errorf("%s: status = %+v, want no error", span.Name(), span.Status())
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package sql
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+)
+
+// TxError is returned by Commit and Rollback when the context given to
+// BeginTx was canceled while they ran and they were killed.
+type TxError struct {
+	// Op is "commit" or "rollback".
+	Op string
+	// OutcomeKnown reports whether the transaction is known to be rolled back.
+	// When it is false a killed commit may or may not have been applied.
+	OutcomeKnown bool
+	// Err is the error of the context.
+	Err error
+	// DriverErr is the error the killed commit or rollback returned.
+	DriverErr error
+}
+
+func (e *TxError) Error() string {
+	var outcome = "outcome unknown"
+	if e.OutcomeKnown {
+		outcome = "rolled back"
+	}
+	if e.DriverErr != nil {
+		return fmt.Sprintf("sql: %s killed (%s): %v: %v", e.Op, outcome, e.Err, e.DriverErr)
+	}
+	return fmt.Sprintf("sql: %s killed (%s): %v", e.Op, outcome, e.Err)
+}
+
+func (e *TxError) Unwrap() error {
+	return e.Err
+}
+
+// cancellableMysqlTx is a transaction whose Commit and Rollback are
+// killed when the context given to BeginTx is canceled.
+type cancellableMysqlTx struct {
+	tx   driver.Tx
+	ctx  context.Context
+	conn *cancellableMysqlConn
+}
+
+func (tx *cancellableMysqlTx) Commit() error {
+	return tx.end("commit", tx.tx.Commit)
+}
+
+func (tx *cancellableMysqlTx) Rollback() error {
+	return tx.end("rollback", tx.tx.Rollback)
+}
+
+// end runs the commit or rollback fn, and kills it if the context of the
+// transaction is canceled first. A killed connection is marked bad so that
+// database/sql discards it, which makes the server roll back whatever the
+// kill left of the transaction.
+func (tx *cancellableMysqlTx) end(op string, fn func() error) error {
+	if tx.ctx.Err() != nil {
+		// database/sql rolls back and discards the connection itself
+		// once the context is canceled.
+		return fn()
+	}
+
+	// fn returns once the kill reached it, or once the kill failed
+	// and closed the socket.
+	// The commit is not in the registry, so the watch can not be refused.
+	var w, _ = tx.conn.watch(tx.ctx, &QueryInfo{Query: op, ConnectionID: tx.conn.connectionID})
+	var err = fn()
+	if !w.done() || err == nil {
+		// The statement completed before the kill reached it.
+		return err
+	}
+
+	// The server rolls back the open transaction of the discarded connection,
+	// so only a commit can have an unknown outcome.
+	return &TxError{Op: op, OutcomeKnown: op == "rollback", Err: tx.ctx.Err(), DriverErr: err}
+}
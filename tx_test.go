@@ -0,0 +1,56 @@
+package sql
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTxEndCancel(t *testing.T) {
+	var tests = []struct {
+		op           string
+		end          func(driver.Tx) error
+		outcomeKnown bool
+	}{
+		{"commit", driver.Tx.Commit, false},
+		{"rollback", driver.Tx.Rollback, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.op, func(t *testing.T) {
+			var server = newFakeServer()
+			var conn = connect(t, newTestConnector(t, server))
+
+			var ctx, cancel = context.WithCancel(context.Background())
+			defer cancel()
+			var tx, err = conn.BeginTx(ctx, driver.TxOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			time.AfterFunc(10*time.Millisecond, cancel)
+
+			err = tt.end(tx)
+			var txErr *TxError
+			if !errors.As(err, &txErr) {
+				t.Fatalf("err = %v, want a *TxError", err)
+			}
+			if txErr.Op != tt.op || txErr.OutcomeKnown != tt.outcomeKnown || !isInterrupted(txErr.DriverErr) {
+				t.Errorf("TxError = %+v, want %s with OutcomeKnown %v", txErr, tt.op, tt.outcomeKnown)
+			}
+			if !errors.Is(err, context.Canceled) {
+				t.Errorf("err = %v, want %v", err, context.Canceled)
+			}
+			if kills := server.killStatements(); len(kills) != 1 {
+				t.Errorf("kills = %v, want one", kills)
+			}
+			if server.sleeping(conn.connectionID.String()) {
+				t.Errorf("%s still running after it returned", tt.op)
+			}
+			if conn.IsValid() {
+				t.Error("killed connection is still valid")
+			}
+		})
+	}
+}
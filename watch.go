@@ -0,0 +1,127 @@
+package sql
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	watchRunning int32 = iota
+	watchFinished
+	watchKilled
+)
+
+// killWatch kills the query of a connection if its context is done
+// before the query returns. No goroutine is started unless the context
+// fires: the kill runs in the goroutine of context.AfterFunc.
+//
+// The state moves once, from watchRunning to either watchFinished (the
+// query returned first) or watchKilled (the context fired first).
+type killWatch struct {
+	state int32 // accessed atomically
+	stop  func() bool
+	mu    sync.Mutex // held while the kill runs
+
+	// driverCtx is given to the wrapped driver instead of the context of
+	// the query. It is only canceled by abort, as the wrapped driver then
+	// closes its socket.
+	driverCtx    context.Context
+	cancelDriver context.CancelFunc
+
+	// The outcome of the kill, set while mu is held.
+	killErr     error
+	killLatency time.Duration
+	killPath    KillPath
+}
+
+// watch starts watching ctx for the query on the connection, which is
+// about to be sent. Connector.Cancel kills the query through the watch.
+// It returns ErrShuttingDown for a query of the registry which must not
+// be sent, as the connector is shutting down.
+func (c *cancellableMysqlConn) watch(ctx context.Context, info *QueryInfo) (*killWatch, error) {
+	var w = &killWatch{}
+	w.driverCtx, w.cancelDriver = context.WithCancel(context.Background())
+	if c.connector != nil {
+		if err := c.connector.queries.sent(info, w); err != nil {
+			return nil, err
+		}
+	}
+	if ctx.Done() == nil {
+		// The context can never fire.
+		return w, nil
+	}
+	w.stop = context.AfterFunc(ctx, func() {
+		w.cancel(ctx, c, info)
+	})
+	return w, nil
+}
+
+// cancel kills the query like kill, because its context is done.
+func (w *killWatch) cancel(ctx context.Context, c *cancellableMysqlConn, info *QueryInfo) bool {
+	return w.run(ctx, c, info, true)
+}
+
+// kill kills the query unless it returned or was killed already, and
+// waits for the kill to complete. It reports whether the query was killed.
+func (w *killWatch) kill(ctx context.Context, c *cancellableMysqlConn, info *QueryInfo) bool {
+	return w.run(ctx, c, info, false)
+}
+
+// run kills the query for kill and cancel, counting it as canceled
+// by its context if cancelled is set.
+func (w *killWatch) run(ctx context.Context, c *cancellableMysqlConn, info *QueryInfo, cancelled bool) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if atomic.CompareAndSwapInt32(&w.state, watchRunning, watchKilled) {
+		if cancelled && c.connector != nil {
+			c.connector.killer.cancelled(info)
+		}
+		var start = time.Now()
+		w.killPath, w.killErr = c.kill(ctx, info, func() error { return w.abort(c) })
+		w.killLatency = time.Since(start)
+	}
+	return atomic.LoadInt32(&w.state) == watchKilled
+}
+
+// abort ends the statement without the server, as the last resort after
+// a failed kill, whatever the network of the connection: the wrapped
+// driver closes its socket once driverCtx is canceled. The socket is also
+// closed directly when it is known, for the statements sent without
+// driverCtx, such as commits.
+func (w *killWatch) abort(c *cancellableMysqlConn) error {
+	w.cancelDriver()
+	if c.netConn != nil {
+		return c.netConn.Close()
+	}
+	return nil
+}
+
+// done ends the watch once the query has returned.
+// It reports whether the query was killed because its context is done,
+// and if so waits for the kill to complete. A query killed by
+// Connector.Cancel is waited for, but not reported.
+func (w *killWatch) done() bool {
+	// The context did not fire if stopped.
+	var stopped = w.stop == nil || w.stop()
+	if atomic.CompareAndSwapInt32(&w.state, watchRunning, watchFinished) {
+		// The query returned before any kill started.
+		return false
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return !stopped
+}
+
+// cancelError returns the error of the killed query on conn, which
+// returned queryErr. It must be called after done reported the kill.
+func (w *killWatch) cancelError(ctx context.Context, conn *cancellableMysqlConn, queryErr error) *CancelError {
+	var e = conn.cancelError(ctx, queryErr)
+	e.Killed = w.killErr == nil
+	e.KillLatency = w.killLatency
+	e.KillErr = w.killErr
+	e.KillPath = w.killPath
+	return e
+}
@@ -0,0 +1,282 @@
+package sql
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// goroutinesSettle waits for the number of goroutines to drop to want
+// and fails the test if it does not.
+func goroutinesSettle(t *testing.T, want int) {
+	t.Helper()
+
+	var deadline = time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > want {
+		if time.Now().After(deadline) {
+			var buf = make([]byte, 1<<16)
+			t.Fatalf("%d goroutines leaked:\n%s", runtime.NumGoroutine()-want, buf[:runtime.Stack(buf, true)])
+		}
+		runtime.Gosched()
+	}
+}
+
+func TestExecContextNoGoroutines(t *testing.T) {
+	var server = newFakeServer()
+	var conn = connect(t, newTestConnector(t, server))
+	var ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+
+	var before = runtime.NumGoroutine()
+	var during int
+	server.onExec = func(context.Context, string) {
+		during = runtime.NumGoroutine()
+	}
+
+	if _, err := conn.ExecContext(ctx, "DO 1", nil); err != nil {
+		t.Fatal(err)
+	}
+	if during != before {
+		t.Errorf("%d goroutines started on the happy path", during-before)
+	}
+}
+
+func TestExecContextCancel(t *testing.T) {
+	var server = newFakeServer()
+	var c = newTestConnector(t, server)
+	var conn = connect(t, c)
+	var before = runtime.NumGoroutine()
+
+	var ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	var _, err = conn.ExecContext(ctx, "SLEEP", nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want %v", err, context.Canceled)
+	}
+	if kills := server.killStatements(); len(kills) != 1 || kills[0] != "KILL QUERY "+conn.connectionID.String() {
+		t.Errorf("kills = %v, want [KILL QUERY %s]", kills, conn.connectionID)
+	}
+	if conn.IsValid() {
+		t.Error("killed connection is still valid")
+	}
+
+	// The kill pool keeps its idle connection; close it before counting.
+	c.killer.pool.Close()
+	goroutinesSettle(t, before)
+}
+
+// TestExecContextCancelOnReturn cancels the context while the result is
+// being returned, which used to block the result-producing goroutine forever.
+func TestExecContextCancelOnReturn(t *testing.T) {
+	var server = newFakeServer()
+	var c = newTestConnector(t, server)
+	var conn = connect(t, c)
+	var before = runtime.NumGoroutine()
+
+	for i := 0; i < 100; i++ {
+		var ctx, cancel = context.WithCancel(context.Background())
+		server.onExec = func(context.Context, string) {
+			cancel()
+		}
+
+		var res, err = conn.ExecContext(ctx, "DO 1", nil)
+		server.onExec = nil
+		if err != nil || res == nil {
+			t.Fatalf("ExecContext = %v, %v; want the result of the completed statement", res, err)
+		}
+	}
+
+	c.killer.pool.Close()
+	goroutinesSettle(t, before)
+}
+
+func TestStmtExecContextCancel(t *testing.T) {
+	var server = newFakeServer()
+	var conn = connect(t, newTestConnector(t, server))
+
+	var stmt, err = conn.PrepareContext(context.Background(), "SLEEP")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err = stmt.(*cancellableMysqlStfmt).ExecContext(ctx, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if server.sleeping(conn.connectionID.String()) {
+		t.Error("statement still running after ExecContext returned")
+	}
+}
+
+func TestKillVerifySkipsFinishedQuery(t *testing.T) {
+	var server = newFakeServer()
+	var conn = connect(t, newTestConnector(t, server, WithKillVerify(true)))
+
+	var ctx, cancel = context.WithCancel(context.Background())
+	var killErr = make(chan error, 1)
+	conn.connector.interceptors = interceptorChain{killRecorder{killErr}}
+	server.onExec = func(_ context.Context, query string) {
+		if !strings.HasPrefix(query, "/*mysqlc:") {
+			t.Errorf("query %q is not marked", query)
+		}
+		// Cancel as the statement finishes.
+		cancel()
+	}
+
+	if _, err := conn.ExecContext(ctx, "DO 1", nil); err != nil {
+		t.Fatal(err)
+	}
+	server.onExec = nil
+
+	select {
+	case err := <-killErr:
+		if err != ErrQueryFinished {
+			t.Errorf("kill error = %v, want %v", err, ErrQueryFinished)
+		}
+	case <-time.After(time.Second):
+		// The statement returned before the kill started.
+	}
+	if kills := server.killStatements(); len(kills) != 0 {
+		t.Errorf("kills = %v, want none", kills)
+	}
+	if !conn.IsValid() {
+		t.Error("connection invalidated by a skipped kill")
+	}
+}
+
+func TestKillVerifyKillsRunningQuery(t *testing.T) {
+	var server = newFakeServer()
+	var conn = connect(t, newTestConnector(t, server, WithKillVerify(true)))
+
+	var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := conn.ExecContext(ctx, "SLEEP", nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if kills := server.killStatements(); len(kills) != 1 {
+		t.Errorf("kills = %v, want one", kills)
+	}
+}
+
+type killRecorder struct {
+	errs chan error
+}
+
+func (killRecorder) BeforeQuery(context.Context, *QueryInfo) error                 { return nil }
+func (killRecorder) AfterQuery(context.Context, *QueryInfo, error)                 {}
+func (killRecorder) BeforeExec(context.Context, *QueryInfo) error                  { return nil }
+func (killRecorder) AfterExec(context.Context, *QueryInfo, error)                  {}
+func (killRecorder) OnPrepare(context.Context, *QueryInfo) error                   { return nil }
+func (killRecorder) OnBeginTx(context.Context, *QueryInfo, driver.TxOptions) error { return nil }
+func (r killRecorder) OnKill(_ context.Context, _ *QueryInfo, err error)           { r.errs <- err }
+
+func TestKillConnection(t *testing.T) {
+	var server = newFakeServer()
+	var conn = connect(t, newTestConnector(t, server, WithKillMode(KillConnection)))
+
+	var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := conn.ExecContext(ctx, "SLEEP", nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want %v", err, context.DeadlineExceeded)
+	}
+	var want = "KILL CONNECTION " + conn.connectionID.String()
+	if kills := server.killStatements(); len(kills) != 1 || kills[0] != want {
+		t.Errorf("kills = %v, want [%s]", kills, want)
+	}
+}
+
+func TestKillEscalate(t *testing.T) {
+	var tests = []struct {
+		query string
+		kills []string
+	}{
+		// The thread goes idle after KILL QUERY.
+		{"SLEEP", []string{"KILL QUERY %s"}},
+		// The statement ignores KILL QUERY.
+		{"SLEEP STUCK", []string{"KILL QUERY %s", "KILL CONNECTION %s"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			var server = newFakeServer()
+			var grace = 50 * time.Millisecond
+			var conn = connect(t, newTestConnector(t, server, WithKillMode(KillEscalate), WithKillGrace(grace)))
+
+			var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+			var start = time.Now()
+			if _, err := conn.ExecContext(ctx, tt.query, nil); !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("err = %v, want %v", err, context.DeadlineExceeded)
+			}
+			var elapsed = time.Since(start)
+
+			var kills = server.killStatements()
+			if len(kills) != len(tt.kills) {
+				t.Fatalf("kills = %v, want %d", kills, len(tt.kills))
+			}
+			for i, kill := range tt.kills {
+				if want := fmt.Sprintf(kill, conn.connectionID); kills[i] != want {
+					t.Errorf("kill %d = %q, want %q", i, kills[i], want)
+				}
+			}
+			if escalated := len(tt.kills) == 2; escalated && elapsed < grace {
+				t.Errorf("connection killed after %v, want the %v grace first", elapsed, grace)
+			}
+		})
+	}
+}
+
+// cancelCounter is a MetricsSink counting the canceled queries.
+type cancelCounter struct {
+	nopMetrics
+	mu        sync.Mutex
+	cancelled int
+}
+
+func (m *cancelCounter) QueryCancelled(MetricLabels) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cancelled++
+}
+
+func (m *cancelCounter) count() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.cancelled
+}
+
+func TestQueryCancelledMetric(t *testing.T) {
+	var server = newFakeServer()
+	var metrics = &cancelCounter{}
+	var c = newTestConnector(t, server, WithMetrics(metrics), WithClassTimeout(StatementOther, 20*time.Millisecond))
+	var conn = connect(t, c)
+
+	// Killed by the watchdog, not by a context.
+	if _, err := conn.Exec("SLEEP", nil); !isInterrupted(err) {
+		t.Errorf("err = %v, want the interrupted statement", err)
+	}
+	if n := metrics.count(); n != 0 {
+		t.Errorf("cancelled = %d after a watchdog kill, want 0", n)
+	}
+
+	conn = connect(t, c)
+	var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := conn.ExecContext(ctx, "SLEEP", nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if n := metrics.count(); n != 1 {
+		t.Errorf("cancelled = %d after a context kill, want 1", n)
+	}
+	if kills := server.killStatements(); len(kills) != 2 {
+		t.Errorf("kills = %v, want two", kills)
+	}
+}
@@ -0,0 +1,126 @@
+package sql
+
+import (
+	"context"
+	"time"
+)
+
+// maxWatchdogInterval bounds the interval at which the watchdog checks
+// the age of the in-flight queries.
+const maxWatchdogInterval = time.Second
+
+// WithClassTimeout sets the timeout of the statements of class, overriding
+// the DSN parameters for them. A zero d exempts the class from timeouts.
+func WithClassTimeout(class StatementClass, d time.Duration) Option {
+	return func(c *Connector) {
+		if c.classTimeouts == nil {
+			c.classTimeouts = make(map[StatementClass]time.Duration)
+		}
+		c.classTimeouts[class] = d
+	}
+}
+
+// queryTimeout returns the timeout of the statements of class, or 0 if
+// they have none.
+func (c *Connector) queryTimeout(class StatementClass) time.Duration {
+	if d, ok := c.classTimeouts[class]; ok {
+		return d
+	}
+
+	var d time.Duration
+	switch class {
+	case StatementSelect:
+		d = c.cfg.selectTimeout
+	case StatementDML:
+		d = c.cfg.dmlTimeout
+	case StatementDDL:
+		d = c.cfg.ddlTimeout
+	}
+	if d > 0 {
+		return d
+	}
+	return c.cfg.queryTimeout
+}
+
+// watchdogInterval returns the interval at which the watchdog runs,
+// or 0 if no statement has a timeout.
+func (c *Connector) watchdogInterval() time.Duration {
+	var shortest time.Duration
+	for _, class := range []StatementClass{StatementSelect, StatementDML, StatementDDL, StatementOther} {
+		if d := c.queryTimeout(class); d > 0 && (shortest == 0 || d < shortest) {
+			shortest = d
+		}
+	}
+	if shortest == 0 {
+		return 0
+	}
+
+	var interval = shortest / 10
+	if interval < minKillPollInterval {
+		interval = minKillPollInterval
+	}
+	if interval > maxWatchdogInterval {
+		interval = maxWatchdogInterval
+	}
+	return interval
+}
+
+// runWatchdog kills the in-flight queries older than their timeout,
+// whatever their context. It runs while there are queries in flight,
+// and is started again by the next one.
+func (c *Connector) runWatchdog(interval time.Duration) {
+	var ticker = time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		var expired []*inFlightQuery
+
+		c.queries.mu.Lock()
+		if len(c.queries.queries) == 0 {
+			c.queries.watching = false
+			c.queries.mu.Unlock()
+			return
+		}
+		for _, q := range c.queries.queries {
+			if q.timedOut {
+				continue
+			}
+			var timeout = c.queryTimeout(classify(q.info.Query))
+			if timeout <= 0 || !q.deadline.IsZero() && !q.deadline.After(q.start.Add(timeout)) {
+				// The context of the query enforces the timeout.
+				continue
+			}
+			if now.Sub(q.start) > timeout {
+				q.timedOut = true
+				expired = append(expired, q)
+			}
+		}
+		c.queries.mu.Unlock()
+
+		for _, q := range expired {
+			go c.killExpired(q, time.Since(q.start))
+		}
+	}
+}
+
+// killExpired kills a query which exceeded its timeout.
+func (c *Connector) killExpired(q *inFlightQuery, age time.Duration) {
+	var ctx = context.Background()
+	var class = classify(q.info.Query)
+	c.logger.Log(ctx, LogEntry{
+		Event:        EventQueryTimedOut,
+		ConnectionID: q.info.ConnectionID,
+		Query:        fingerprint(q.info.Query),
+		Cause:        timeoutError(class, c.queryTimeout(class)),
+		Age:          age,
+	})
+	if q.info.ConnectionID == 0 {
+		return
+	}
+	// The kill runs after the registry was unlocked: the query may have
+	// returned since, and its connection moved on to another statement.
+	// The watch of the query only kills it while it is running.
+	if w := c.queries.watch(q); w != nil {
+		w.kill(ctx, q.conn, q.info)
+	}
+}
@@ -0,0 +1,125 @@
+package sql
+
+import (
+	"context"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWatchdogKillsContextlessExec(t *testing.T) {
+	var server = newFakeServer()
+	var mu sync.Mutex
+	var timedOut []LogEntry
+	var logger = LoggerFunc(func(_ context.Context, e LogEntry) {
+		if e.Event == EventQueryTimedOut {
+			mu.Lock()
+			defer mu.Unlock()
+			timedOut = append(timedOut, e)
+		}
+	})
+	var c = newTestConnector(t, server, WithLogger(logger), WithClassTimeout(StatementOther, 20*time.Millisecond))
+	var conn = connect(t, c)
+	var before = runtime.NumGoroutine()
+
+	var _, err = conn.Exec("SLEEP", nil)
+	if !isInterrupted(err) {
+		t.Errorf("err = %v, want the interrupted statement", err)
+	}
+
+	mu.Lock()
+	if len(timedOut) != 1 || timedOut[0].Age < 20*time.Millisecond || timedOut[0].ConnectionID != conn.connectionID {
+		t.Errorf("timed out = %+v, want one entry aged 20ms or more", timedOut)
+	}
+	mu.Unlock()
+
+	// The watchdog stops once no query is in flight.
+	c.killer.pool.Close()
+	goroutinesSettle(t, before)
+}
+
+func TestWatchdogExemptClass(t *testing.T) {
+	var c = newTestConnector(t, newFakeServer(), WithClassTimeout(StatementSelect, 0))
+	c.cfg.queryTimeout = time.Second
+
+	if d := c.queryTimeout(StatementSelect); d != 0 {
+		t.Errorf("SELECT timeout = %v, want none", d)
+	}
+	if d := c.queryTimeout(StatementDML); d != time.Second {
+		t.Errorf("DML timeout = %v, want %v", d, time.Second)
+	}
+}
+
+func TestWatchdogVerifiesContextlessExec(t *testing.T) {
+	var server = newFakeServer()
+	var c = newTestConnector(t, server, WithKillVerify(true), WithClassTimeout(StatementOther, 20*time.Millisecond))
+	var conn = connect(t, c)
+	server.onExec = func(_ context.Context, query string) {
+		if !strings.HasPrefix(query, "/*mysqlc:") && !strings.HasPrefix(query, "KILL ") {
+			t.Errorf("query %q is not marked", query)
+		}
+	}
+
+	if _, err := conn.Exec("SLEEP", nil); !isInterrupted(err) {
+		t.Errorf("err = %v, want the interrupted statement", err)
+	}
+	if kills := server.killStatements(); len(kills) != 1 {
+		t.Errorf("kills = %v, want one", kills)
+	}
+}
+
+func TestWatchdogSkipsFinishedQuery(t *testing.T) {
+	var server = newFakeServer()
+	var c = newTestConnector(t, server)
+	var conn = connect(t, c)
+
+	var info = &QueryInfo{Query: "SLEEP", ConnectionID: conn.connectionID}
+	if err := c.queries.enter(context.Background(), conn, info); err != nil {
+		t.Fatal(err)
+	}
+	defer c.queries.leave(info)
+	var q = c.queries.get(info.id)
+
+	// The statement returned between the watchdog tick and the kill,
+	// but the query has not left the registry yet.
+	var w, err = conn.watch(context.Background(), info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.done()
+	c.killExpired(q, time.Minute)
+	if kills := server.killStatements(); len(kills) != 0 {
+		t.Errorf("kills = %v, want none", kills)
+	}
+	if !conn.IsValid() {
+		t.Error("connection invalidated by a skipped kill")
+	}
+}
+
+func TestWatchdogKillCompletesFirst(t *testing.T) {
+	var server = newFakeServer()
+	var mu sync.Mutex
+	var killed bool
+	server.onExec = func(_ context.Context, query string) {
+		if strings.HasPrefix(query, "KILL ") {
+			// The statement returns while the kill is still being sent.
+			time.Sleep(20 * time.Millisecond)
+			mu.Lock()
+			defer mu.Unlock()
+			killed = true
+		}
+	}
+	var c = newTestConnector(t, server, WithClassTimeout(StatementOther, 20*time.Millisecond))
+	var conn = connect(t, c)
+
+	if _, err := conn.Exec("SLEEP", nil); !isInterrupted(err) {
+		t.Errorf("err = %v, want the interrupted statement", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if !killed {
+		t.Error("Exec returned before the kill completed, leaving it to hit the next statement")
+	}
+}